@@ -0,0 +1,70 @@
+package methodmux
+
+import (
+	"context"
+	"net/http"
+)
+
+type nextKey struct{}
+
+// HandleChain registers, for the given method and pattern, a handler
+// that calls handlers in order. Each of them can call Next to serve
+// the request with the rest of the chain, and act on the response
+// afterwards. A handler that neither calls Next nor writes a response
+// passes the request on to the next handler when it returns; the
+// chain stops at the first handler that writes a response without
+// calling Next.
+//
+// HandleChain panics if handlers is empty or any of them is nil.
+func (mux *ServeMux) HandleChain(method, pattern string, handlers ...http.Handler) {
+	if len(handlers) == 0 {
+		panic("methodmux: nil handler")
+	}
+	for _, h := range handlers {
+		if h == nil {
+			panic("methodmux: nil handler")
+		}
+	}
+
+	mux.Handle(method, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveChain(&statusWriter{ResponseWriter: w}, r, handlers)
+	}))
+}
+
+// serveChain serves r with the first of handlers, which can call Next
+// to serve it with the others.
+func serveChain(sw *statusWriter, r *http.Request, handlers []http.Handler) {
+	if len(handlers) == 0 {
+		return
+	}
+
+	var calledNext bool
+	next := func(w http.ResponseWriter, r *http.Request) {
+		if calledNext {
+			return
+		}
+		calledNext = true
+		next, ok := w.(interface{ recorder() *statusWriter })
+		if !ok {
+			next = &statusWriter{ResponseWriter: w}
+		}
+		serveChain(next.recorder(), r, handlers[1:])
+	}
+
+	status, bytes := sw.status, sw.bytes
+	handlers[0].ServeHTTP(sw.withOptional(), r.WithContext(context.WithValue(r.Context(), nextKey{}, next)))
+
+	if !calledNext && sw.status == status && sw.bytes == bytes {
+		serveChain(sw, r, handlers[1:])
+	}
+}
+
+// Next serves the request with the handlers that follow the calling
+// one in the chain registered with HandleChain. It does nothing if the
+// calling handler is the last one, if it has already been called, or
+// if the request is not served by HandleChain.
+func Next(w http.ResponseWriter, r *http.Request) {
+	if next, ok := r.Context().Value(nextKey{}).(func(http.ResponseWriter, *http.Request)); ok {
+		next(w, r)
+	}
+}
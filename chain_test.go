@@ -0,0 +1,57 @@
+package methodmux_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/pierreprinetti/go-methodmux"
+)
+
+func TestHandleChain(t *testing.T) {
+	setHeader := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("X-Chain", "first")
+	})
+	writeBody := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("body"))
+	})
+	deny := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(403)
+	})
+	wrap := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<"))
+		Next(w, r)
+		w.Write([]byte(">"))
+	})
+
+	testCases := [...]struct {
+		name           string
+		handlers       []http.Handler
+		expectedCode   int
+		expectedHeader string
+		expectedBody   string
+	}{
+		{"header then body", []http.Handler{setHeader, writeBody}, 200, "first", "body"},
+		{"stops on response", []http.Handler{deny, writeBody}, 403, "", ""},
+		{"explicit next", []http.Handler{wrap, setHeader, writeBody}, 200, "first", "<body>"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mux := New()
+			mux.HandleChain("GET", "/", tc.handlers...)
+
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest("GET", "/", nil))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+			if want, have := tc.expectedHeader, rw.Header().Get("X-Chain"); have != want {
+				t.Errorf("expected header %q, found %q", want, have)
+			}
+			if want, have := tc.expectedBody, rw.Body.String(); have != want {
+				t.Errorf("expected body %q, found %q", want, have)
+			}
+		})
+	}
+}
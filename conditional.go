@@ -0,0 +1,108 @@
+package methodmux
+
+import (
+	"net/http"
+)
+
+// handleIf registers the handler for the given method and pattern, only
+// for the requests that match. Conditional handlers are checked in
+// registration order, before the handler registered with Handle for
+// the same method and pattern. If none of them matches and no such
+// handler exists, the request falls through to the other patterns, as
+// if the pattern was not registered.
+func (mux *ServeMux) handleIf(method, pattern string, match func(*http.Request) bool, handler http.Handler) {
+	method, pattern = mux.onRegister(method, pattern)
+
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	if handler == nil {
+		panic("methodmux: nil handler")
+	}
+
+	rt := mux.routeFor(method, pattern)
+	rt.conds = append(rt.conds, conditional{match: match, handler: handler})
+}
+
+// HandleQuery registers the handler for the given method and pattern,
+// only for the requests whose query parameter key has the given value.
+// Query-scoped handlers are checked in registration order, before the
+// handler registered with Handle for the same method and pattern, if
+// any. If none matches and no such handler exists, the request falls
+// through to the other patterns, as if the pattern was not registered.
+func (mux *ServeMux) HandleQuery(method, pattern, key, value string, handler http.Handler) {
+	mux.handleIf(method, pattern, func(r *http.Request) bool {
+		return r.URL.Query().Get(key) == value
+	}, handler)
+}
+
+// HandleHeader registers the handler for the given method and pattern,
+// only for the requests whose header key has the given value, such as
+// an "Api-Version" of "2". Header-scoped handlers are checked, and the
+// requests that none matches fall through, like with the query-scoped
+// ones; see HandleQuery.
+func (mux *ServeMux) HandleHeader(method, pattern, key, value string, handler http.Handler) {
+	mux.handleIf(method, pattern, func(r *http.Request) bool {
+		return r.Header.Get(key) == value
+	}, handler)
+}
+
+// MatchPriority tells when the matchers registered with HandleMatch are
+// consulted.
+type MatchPriority int
+
+const (
+	// BeforeRoutes matchers are consulted before any registered
+	// pattern.
+	BeforeRoutes MatchPriority = iota
+
+	// BeforeNotFound matchers are consulted as a last resort, for the
+	// requests that would otherwise get a 404.
+	BeforeNotFound
+)
+
+// customMatcher is a handler registered with HandleMatch.
+type customMatcher struct {
+	match   func(*http.Request) bool
+	handler http.Handler
+}
+
+// HandleMatch registers the handler for the requests that matcher
+// reports true for, regardless of their method and path. Matchers with
+// the same priority are consulted in registration order; the first that
+// matches wins.
+//
+// Matchers are consulted one by one, so their cost adds up on every
+// request they are consulted for: BeforeRoutes matchers slow down
+// every request, BeforeNotFound matchers only the unmatched ones.
+// Matchers are called with the mux locked for reading, and must not
+// register handlers.
+func (mux *ServeMux) HandleMatch(matcher func(*http.Request) bool, handler http.Handler, priority MatchPriority) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	if handler == nil {
+		panic("methodmux: nil handler")
+	}
+
+	m := customMatcher{match: matcher, handler: handler}
+	switch priority {
+	case BeforeRoutes:
+		mux.matchFirst = append(mux.matchFirst, m)
+	case BeforeNotFound:
+		mux.matchLast = append(mux.matchLast, m)
+	default:
+		panic("methodmux: unknown match priority")
+	}
+}
+
+// firstMatch returns the handler of the first matcher matching r, or
+// nil.
+func firstMatch(matchers []customMatcher, r *http.Request) http.Handler {
+	for _, m := range matchers {
+		if m.match(r) {
+			return m.handler
+		}
+	}
+	return nil
+}
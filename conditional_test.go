@@ -0,0 +1,162 @@
+package methodmux_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/pierreprinetti/go-methodmux"
+)
+
+func TestHandleQuery(t *testing.T) {
+	testCases := [...]struct {
+		path         string
+		expectedCode int
+	}{
+		{"/report?format=csv", 201},
+		{"/report?format=xml", 202},
+		{"/report?format=json", 200},
+		{"/report", 200},
+		{"/export?format=csv", 203},
+		{"/export?format=json", 404},
+		{"/export", 404},
+	}
+
+	mux := New()
+	mux.Handle("GET", "/report", serve(200))
+	mux.HandleQuery("GET", "/report", "format", "csv", serve(201))
+	mux.HandleQuery("GET", "/report", "format", "xml", serve(202))
+	mux.HandleQuery("GET", "/export", "format", "csv", serve(203))
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest("GET", tc.path, nil))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+
+	t.Run("checks in registration order", func(t *testing.T) {
+		mux := New()
+		mux.HandleQuery("GET", "/", "a", "1", serve(201))
+		mux.HandleQuery("GET", "/", "a", "1", serve(202))
+
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, httptest.NewRequest("GET", "/?a=1", nil))
+		if want, have := 201, rw.Code; have != want {
+			t.Errorf("expected status code %d, found %d", want, have)
+		}
+	})
+
+	t.Run("falls through", func(t *testing.T) {
+		mux := New()
+		mux.Handle("GET", "/", serve(201))
+		mux.HandleQuery("GET", "/x", "format", "csv", serve(202))
+		mux.HandleQuery("GET", "/y/", "format", "xml", serve(203))
+
+		for path, want := range map[string]int{
+			"/x?format=csv":   202,
+			"/x?format=json":  201,
+			"/y/z?format=xml": 203,
+			"/y/z":            201,
+		} {
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest("GET", path, nil))
+			if have := rw.Code; have != want {
+				t.Errorf("%s: expected status code %d, found %d", path, want, have)
+			}
+		}
+	})
+}
+
+func TestHandleHeader(t *testing.T) {
+	testCases := [...]struct {
+		name         string
+		path         string
+		version      string
+		expectedCode int
+	}{
+		{"matching header", "/items", "2", 202},
+		{"other matching header", "/items", "3", 203},
+		{"different value", "/items", "1", 200},
+		{"missing header", "/items", "", 200},
+		{"no unconditional handler", "/beta", "1", 404},
+		{"no unconditional handler, matching header", "/beta", "2", 202},
+	}
+
+	mux := New()
+	mux.Handle("GET", "/items", serve(200))
+	mux.HandleHeader("GET", "/items", "Api-Version", "2", serve(202))
+	mux.HandleHeader("GET", "/items", "Api-Version", "3", serve(203))
+	mux.HandleHeader("GET", "/beta", "Api-Version", "2", serve(202))
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.path, nil)
+			if tc.version != "" {
+				req.Header.Set("Api-Version", tc.version)
+			}
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, req)
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+
+	t.Run("falls through", func(t *testing.T) {
+		mux := New()
+		mux.Handle("GET", "/", serve(201))
+		mux.HandleHeader("GET", "/beta", "Api-Version", "2", serve(202))
+
+		for version, want := range map[string]int{"2": 202, "1": 201} {
+			req := httptest.NewRequest("GET", "/beta", nil)
+			req.Header.Set("Api-Version", version)
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, req)
+			if have := rw.Code; have != want {
+				t.Errorf("Api-Version %s: expected status code %d, found %d", version, want, have)
+			}
+		}
+	})
+}
+
+func TestHandleMatch(t *testing.T) {
+	testCases := [...]struct {
+		method       string
+		path         string
+		header       string
+		expectedCode int
+	}{
+		{"GET", "/items", "", 200},
+		{"GET", "/items", "first", 251},
+		{"GET", "/missing", "first", 251},
+		{"GET", "/items", "last", 200},
+		{"POST", "/items", "last", 405},
+		{"GET", "/missing", "last", 252},
+		{"GET", "/missing", "", 404},
+	}
+
+	mux := New()
+	mux.Handle("GET", "/items", serve(200))
+	mux.HandleMatch(func(r *http.Request) bool {
+		return r.Header.Get("X-Match") == "first"
+	}, serve(251), BeforeRoutes)
+	mux.HandleMatch(func(r *http.Request) bool {
+		return r.Header.Get("X-Match") == "last"
+	}, serve(252), BeforeNotFound)
+
+	for _, tc := range testCases {
+		t.Run(tc.method+" "+tc.path+" "+tc.header, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			req.Header.Set("X-Match", tc.header)
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, req)
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package methodmux
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// controllerMethods maps the method name prefixes recognized by
+// HandleController to the HTTP methods.
+var controllerMethods = [...]struct {
+	prefix, method string
+}{
+	{"Get", http.MethodGet},
+	{"Head", http.MethodHead},
+	{"Post", http.MethodPost},
+	{"Put", http.MethodPut},
+	{"Patch", http.MethodPatch},
+	{"Delete", http.MethodDelete},
+	{"Connect", http.MethodConnect},
+	{"Options", http.MethodOptions},
+	{"Trace", http.MethodTrace},
+}
+
+// HandleController registers the exported methods of controller that
+// have the signature of an http.HandlerFunc, deriving the HTTP method
+// and the pattern from their names. The name must start with an HTTP
+// method in camel case, such as "Get" or "Delete", followed by the
+// resource name, which is converted to a kebab-case path segment
+// appended to prefix:
+//
+//	GetItems(w, r)          GET prefix/items
+//	PostUserProfiles(w, r)  POST prefix/user-profiles
+//	Get(w, r)               GET prefix
+//
+// Exported methods that don't follow this convention are ignored. Like
+// Handle, HandleController panics if a handler already exists for any
+// of the combinations of method and pattern.
+func (mux *ServeMux) HandleController(prefix string, controller interface{}) {
+	v := reflect.ValueOf(controller)
+	t := v.Type()
+	for i := 0; i < t.NumMethod(); i++ {
+		handler, ok := v.Method(i).Interface().(func(http.ResponseWriter, *http.Request))
+		if !ok {
+			continue
+		}
+		method, resource, ok := splitControllerMethod(t.Method(i).Name)
+		if !ok {
+			continue
+		}
+		pattern := prefix
+		if resource != "" {
+			pattern = strings.TrimSuffix(prefix, "/") + "/" + resource
+		}
+		mux.HandleFunc(method, pattern, handler)
+	}
+}
+
+// splitControllerMethod splits the name of a controller method in the
+// HTTP method and the kebab-case resource name.
+func splitControllerMethod(name string) (method, resource string, ok bool) {
+	for _, m := range controllerMethods {
+		if !strings.HasPrefix(name, m.prefix) {
+			continue
+		}
+		rest := name[len(m.prefix):]
+		if rest != "" && !unicode.IsUpper(rune(rest[0])) {
+			continue
+		}
+		var b strings.Builder
+		for i, c := range rest {
+			if unicode.IsUpper(c) {
+				if i > 0 {
+					b.WriteByte('-')
+				}
+				c = unicode.ToLower(c)
+			}
+			b.WriteRune(c)
+		}
+		return m.method, b.String(), true
+	}
+	return "", "", false
+}
@@ -0,0 +1,45 @@
+package methodmux_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/pierreprinetti/go-methodmux"
+)
+
+type itemsController struct{}
+
+func (itemsController) GetItems(w http.ResponseWriter, r *http.Request)         { w.WriteHeader(200) }
+func (itemsController) PostUserProfiles(w http.ResponseWriter, r *http.Request) { w.WriteHeader(201) }
+func (itemsController) Get(w http.ResponseWriter, r *http.Request)              { w.WriteHeader(202) }
+func (itemsController) Getaway(w http.ResponseWriter, r *http.Request)          { w.WriteHeader(500) }
+func (itemsController) GetName() string                                         { return "items" }
+
+func TestHandleController(t *testing.T) {
+	testCases := [...]struct {
+		method       string
+		path         string
+		expectedCode int
+	}{
+		{"GET", "/api/items", 200},
+		{"POST", "/api/user-profiles", 201},
+		{"GET", "/api", 202},
+		{"POST", "/api/items", 405},
+		{"GET", "/api/name", 404},
+		{"GET", "/api/away", 404},
+	}
+
+	mux := New()
+	mux.HandleController("/api", itemsController{})
+
+	for _, tc := range testCases {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest(tc.method, tc.path, nil))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+}
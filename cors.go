@@ -0,0 +1,128 @@
+package methodmux
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig configures the Cross-Origin Resource Sharing layer of a
+// ServeMux; see ServeMux.CORS.
+type CORSConfig struct {
+	// AllowedOrigins are the origins allowed to send cross-origin
+	// requests, such as "https://a.example". The origin "*" allows
+	// any origin. Origins are compared case-insensitively.
+	AllowedOrigins []string
+
+	// AllowedMethods, if not empty, restricts the methods announced in
+	// the answers to preflight requests. The announced methods are
+	// those with a handler matching the request path that are also in
+	// AllowedMethods.
+	AllowedMethods []string
+
+	// AllowedHeaders are the request headers that cross-origin requests
+	// may carry, announced in the answers to preflight requests.
+	AllowedHeaders []string
+
+	// MaxAge, if positive, is how long the answers to preflight
+	// requests may be cached, rounded down to the second.
+	MaxAge time.Duration
+}
+
+// allowOrigin returns the value of the Access-Control-Allow-Origin
+// header for the given Origin header, or an empty string if the origin
+// is not allowed.
+func (c *CORSConfig) allowOrigin(origin string) string {
+	if origin == "" {
+		return ""
+	}
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			return "*"
+		}
+		if strings.EqualFold(allowed, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// setOrigin sets the Access-Control-Allow-Origin header on the response
+// to r, if its origin is allowed and the header is not set yet. Unless
+// any origin is allowed, the response lists "Origin" in its Vary
+// header, whether the origin is allowed or not, as the answer depends
+// on it.
+func (c *CORSConfig) setOrigin(w http.ResponseWriter, r *http.Request) {
+	if !c.anyOrigin() {
+		addVary(w.Header(), "Origin")
+	}
+	origin := c.allowOrigin(r.Header.Get("Origin"))
+	if origin == "" || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+}
+
+// anyOrigin reports whether AllowedOrigins allows any origin with "*".
+func (c *CORSConfig) anyOrigin() bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// isPreflight reports whether r is a CORS preflight request from an
+// allowed origin.
+func (c *CORSConfig) isPreflight(r *http.Request) bool {
+	return r.Header.Get("Access-Control-Request-Method") != "" && c.allowOrigin(r.Header.Get("Origin")) != ""
+}
+
+// allowMethod reports whether method may be announced; see
+// AllowedMethods.
+func (c *CORSConfig) allowMethod(method string) bool {
+	if len(c.AllowedMethods) == 0 {
+		return true
+	}
+	for _, m := range c.AllowedMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// preflight returns the match for a preflight request, given the
+// methods with a pattern matching it. If none of them is allowed, the
+// returned pattern is empty. The caller must hold the read lock.
+func (mux *ServeMux) preflight(allowed map[string][]string) match {
+	var methods []string
+	for method := range allowed {
+		if mux.CORS.allowMethod(method) {
+			methods = append(methods, method)
+		}
+	}
+	if len(methods) == 0 {
+		return match{}
+	}
+	sort.Strings(methods)
+
+	allowMethods := strings.Join(methods, ", ")
+	allowHeaders := strings.Join(mux.CORS.AllowedHeaders, ", ")
+	maxAge := int64(mux.CORS.MaxAge / time.Second)
+
+	return match{kind: MatchCustom, pattern: allowed[methods[0]][0], handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.CORS.setOrigin(w, r)
+		w.Header().Set("Access-Control-Allow-Methods", allowMethods)
+		if allowHeaders != "" {
+			w.Header().Set("Access-Control-Allow-Headers", allowHeaders)
+		}
+		if maxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.FormatInt(maxAge, 10))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})}
+}
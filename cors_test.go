@@ -0,0 +1,108 @@
+package methodmux_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/pierreprinetti/go-methodmux"
+)
+
+func TestCORS(t *testing.T) {
+	mux := New()
+	mux.CORS = &CORSConfig{
+		AllowedOrigins: []string{"https://a.example"},
+		AllowedMethods: []string{"GET", "PUT"},
+		AllowedHeaders: []string{"Content-Type", "Authorization"},
+		MaxAge:         10 * time.Minute,
+	}
+	mux.Handle("GET", "/items", serve(200))
+	mux.Handle("PUT", "/items", serve(200))
+	mux.Handle("DELETE", "/items", serve(200))
+	mux.Handle("GET", "/own", serve(200))
+	mux.Handle("OPTIONS", "/own", serve(200))
+
+	testCases := [...]struct {
+		name                 string
+		method               string
+		path                 string
+		origin               string
+		requestMethod        string
+		expectedCode         int
+		expectedAllowOrigin  string
+		expectedAllowMethods string
+	}{
+		{"preflight", "OPTIONS", "/items", "https://a.example", "PUT", 204, "https://a.example", "GET, PUT"},
+		{"preflight from another origin", "OPTIONS", "/items", "https://b.example", "PUT", 405, "", ""},
+		{"preflight for an unknown path", "OPTIONS", "/missing", "https://a.example", "PUT", 404, "", ""},
+		{"OPTIONS handler", "OPTIONS", "/own", "https://a.example", "GET", 200, "https://a.example", ""},
+		{"plain OPTIONS", "OPTIONS", "/items", "https://a.example", "", 405, "https://a.example", ""},
+		{"actual request", "PUT", "/items", "https://a.example", "", 200, "https://a.example", ""},
+		{"actual request from another origin", "PUT", "/items", "https://b.example", "", 200, "", ""},
+		{"same-origin request", "GET", "/items", "", "", 200, "", ""},
+		{"not found", "GET", "/missing", "https://a.example", "", 404, "", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+			if tc.requestMethod != "" {
+				req.Header.Set("Access-Control-Request-Method", tc.requestMethod)
+			}
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, req)
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+			if want, have := tc.expectedAllowOrigin, rw.Header().Get("Access-Control-Allow-Origin"); have != want {
+				t.Errorf("expected Access-Control-Allow-Origin %q, found %q", want, have)
+			}
+			if want, have := tc.expectedAllowMethods, rw.Header().Get("Access-Control-Allow-Methods"); have != want {
+				t.Errorf("expected Access-Control-Allow-Methods %q, found %q", want, have)
+			}
+			if tc.expectedCode != 404 {
+				if want, have := "Origin", strings.Join(rw.Header().Values("Vary"), ", "); have != want {
+					t.Errorf("expected Vary %q, found %q", want, have)
+				}
+			}
+		})
+	}
+
+	t.Run("preflight headers", func(t *testing.T) {
+		req := httptest.NewRequest("OPTIONS", "/items", nil)
+		req.Header.Set("Origin", "https://a.example")
+		req.Header.Set("Access-Control-Request-Method", "GET")
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, req)
+		if want, have := "Content-Type, Authorization", rw.Header().Get("Access-Control-Allow-Headers"); have != want {
+			t.Errorf("expected Access-Control-Allow-Headers %q, found %q", want, have)
+		}
+		if want, have := "600", rw.Header().Get("Access-Control-Max-Age"); have != want {
+			t.Errorf("expected Access-Control-Max-Age %q, found %q", want, have)
+		}
+		if want, have := "Origin", strings.Join(rw.Header().Values("Vary"), ", "); have != want {
+			t.Errorf("expected Vary %q, found %q", want, have)
+		}
+	})
+
+	t.Run("any origin", func(t *testing.T) {
+		mux := New()
+		mux.CORS = &CORSConfig{AllowedOrigins: []string{"*"}}
+		mux.Handle("GET", "/items", serve(200))
+
+		req := httptest.NewRequest("GET", "/items", nil)
+		req.Header.Set("Origin", "https://b.example")
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, req)
+		if want, have := "*", rw.Header().Get("Access-Control-Allow-Origin"); have != want {
+			t.Errorf("expected Access-Control-Allow-Origin %q, found %q", want, have)
+		}
+		if vary := rw.Header().Get("Vary"); vary != "" {
+			t.Errorf("expected no Vary header, found %q", vary)
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package methodmux
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// debugRoute is a route as rendered by DebugHandler.
+type debugRoute struct {
+	Method  string `json:"method"`
+	Pattern string `json:"pattern"`
+	Hits    uint64 `json:"hits"`
+}
+
+// debugPage is the data rendered by DebugHandler.
+type debugPage struct {
+	Routes []debugRoute `json:"routes"`
+	Stats  Stats        `json:"stats"`
+}
+
+var debugTemplate = template.Must(template.New("debug").Parse(`<!DOCTYPE html>
+<html>
+<head><title>methodmux</title></head>
+<body>
+<h1>Routes</h1>
+<table>
+<tr><th>Method</th><th>Pattern</th><th>Hits</th></tr>
+{{range .Routes}}<tr><td>{{.Method}}</td><td>{{.Pattern}}</td><td>{{.Hits}}</td></tr>
+{{end}}</table>
+<h1>Stats</h1>
+<p>Requests: {{.Stats.Requests}}</p>
+<p>Not Found: {{.Stats.NotFound}}</p>
+<p>Method Not Allowed: {{.Stats.MethodNotAllowed}}</p>
+</body>
+</html>
+`))
+
+// DebugHandler returns a handler that renders the routes registered
+// to mux with Handle, along with their hit counts and the request
+// counters of mux. The page is rendered as JSON if the request accepts
+// "application/json", and as HTML otherwise. It is meant to be
+// registered on an internal endpoint, such as "/debug/methodmux".
+func (mux *ServeMux) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := debugPage{Stats: mux.Stats()}
+		for _, route := range mux.Routes() {
+			page.Routes = append(page.Routes, debugRoute{
+				Method:  route.Method,
+				Pattern: route.Pattern,
+				Hits:    page.Stats.Hits[route.Method+" "+route.Pattern],
+			})
+		}
+
+		if strings.Contains(r.Header.Get("Accept"), "application/json") {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(page)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		debugTemplate.Execute(w, page)
+	})
+}
@@ -0,0 +1,60 @@
+package methodmux_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/pierreprinetti/go-methodmux"
+)
+
+func TestDebugHandler(t *testing.T) {
+	mux := New()
+	mux.Handle("GET", "/items/", serve(200))
+	mux.Handle("POST", "/items/", serve(201))
+	mux.Handle("GET", "/debug/methodmux", mux.DebugHandler())
+
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/items/42", nil))
+
+	t.Run("html", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, httptest.NewRequest("GET", "/debug/methodmux", nil))
+		if want, have := 200, rw.Code; have != want {
+			t.Fatalf("expected status code %d, found %d", want, have)
+		}
+		for _, want := range []string{"<td>GET</td><td>/items/</td><td>1</td>", "<td>POST</td><td>/items/</td><td>0</td>"} {
+			if !strings.Contains(rw.Body.String(), want) {
+				t.Errorf("expected the page to contain %q, found %q", want, rw.Body.String())
+			}
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/debug/methodmux", nil)
+		req.Header.Set("Accept", "application/json")
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, req)
+
+		var page struct {
+			Routes []struct {
+				Method  string `json:"method"`
+				Pattern string `json:"pattern"`
+				Hits    uint64 `json:"hits"`
+			} `json:"routes"`
+			Stats Stats `json:"stats"`
+		}
+		if err := json.NewDecoder(rw.Body).Decode(&page); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want, have := 3, len(page.Routes); have != want {
+			t.Fatalf("expected %d routes, found %d", want, have)
+		}
+		if route := page.Routes[1]; route.Method != "GET" || route.Pattern != "/items/" || route.Hits != 1 {
+			t.Errorf("expected GET /items/ with 1 hit, found %s %s with %d hits", route.Method, route.Pattern, route.Hits)
+		}
+		if page.Stats.Requests < 3 {
+			t.Errorf("expected at least 3 requests, found %d", page.Stats.Requests)
+		}
+	})
+}
@@ -0,0 +1,83 @@
+package methodmux
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Explain returns a human-readable account of how mux resolves r: the
+// method that is looked up, the canonical path, the outcome of the
+// lookup with the request method and with the other methods, and the
+// resulting response. It is meant for debugging route tables.
+func (mux *ServeMux) Explain(r *http.Request) string {
+	result := mux.resolve(r)
+
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+
+	method := r.Method
+	if mux.NormalizeMethod {
+		method = strings.TrimSpace(method)
+	}
+	if mux.CaseInsensitivePath {
+		r = withPath(r, lowerASCII(r.URL.Path))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "method: %q\n", method)
+	fmt.Fprintf(&b, "host: %q\n", r.Host)
+	canonical := r.URL.Path
+	if method != http.MethodConnect {
+		canonical = cleanPath(canonical)
+	}
+	fmt.Fprintf(&b, "path: %q (canonical: %q)\n", r.URL.Path, canonical)
+
+	if m, exists := mux.m[method]; exists {
+		fmt.Fprintf(&b, "%s: %s\n", method, describe(mux.lookup(m, r)))
+	} else {
+		fmt.Fprintf(&b, "%s: no handlers registered\n", method)
+	}
+
+	var others []string
+	for _, other := range mux.methods {
+		if other == method {
+			continue
+		}
+		if crossMethod := mux.lookup(mux.m[other], r); crossMethod.pattern != "" {
+			others = append(others, fmt.Sprintf("%s (%s)", other, describe(crossMethod)))
+		}
+	}
+	if len(others) == 0 {
+		others = append(others, "none")
+	}
+	fmt.Fprintf(&b, "other methods matching: %s\n", strings.Join(others, ", "))
+
+	switch result.kind {
+	case MatchRoute:
+		fmt.Fprintf(&b, "result: served by pattern %q\n", result.pattern)
+	case MatchRedirect:
+		fmt.Fprintf(&b, "result: redirect to the canonical path, then pattern %q\n", result.pattern)
+	case MatchCustom:
+		b.WriteString("result: served by a custom matcher\n")
+	case MatchMethodNotAllowed:
+		b.WriteString("result: 405 Method Not Allowed\n")
+	case MatchNotFound:
+		b.WriteString("result: 404 Not Found\n")
+	}
+	return b.String()
+}
+
+// describe returns a short description of the outcome of a lookup.
+func describe(m match) string {
+	switch m.kind {
+	case MatchRoute:
+		return fmt.Sprintf("matches pattern %q", m.pattern)
+	case MatchRedirect:
+		return fmt.Sprintf("redirects to pattern %q", m.pattern)
+	case MatchCustom:
+		return fmt.Sprintf("matches unmanaged pattern %q", m.pattern)
+	default:
+		return "no match"
+	}
+}
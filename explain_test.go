@@ -0,0 +1,55 @@
+package methodmux_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/pierreprinetti/go-methodmux"
+)
+
+func TestExplain(t *testing.T) {
+	testCases := [...]struct {
+		method   string
+		path     string
+		expected []string
+	}{
+		{"GET", "/items/42", []string{
+			`method: "GET"`,
+			`GET: matches pattern "/items/"`,
+			`other methods matching: POST (matches pattern "/items/")`,
+			`result: served by pattern "/items/"`,
+		}},
+		{"DELETE", "/items/42", []string{
+			`method: "DELETE"`,
+			`DELETE: no handlers registered`,
+			`other methods matching: GET (matches pattern "/items/"), POST (matches pattern "/items/")`,
+			`result: 405 Method Not Allowed`,
+		}},
+		{"GET", "/a/../missing", []string{
+			`path: "/a/../missing" (canonical: "/missing")`,
+			`GET: no match`,
+			`other methods matching: none`,
+			`result: 404 Not Found`,
+		}},
+		{"GET", "/items", []string{
+			`GET: redirects to pattern "/items/"`,
+			`result: redirect to the canonical path, then pattern "/items/"`,
+		}},
+	}
+
+	mux := New()
+	mux.Handle("GET", "/items/", serve(200))
+	mux.Handle("POST", "/items/", serve(201))
+
+	for _, tc := range testCases {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			explanation := mux.Explain(httptest.NewRequest(tc.method, tc.path, nil))
+			for _, want := range tc.expected {
+				if !strings.Contains(explanation, want+"\n") {
+					t.Errorf("expected the explanation to contain %q, found:\n%s", want, explanation)
+				}
+			}
+		})
+	}
+}
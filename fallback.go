@@ -0,0 +1,76 @@
+package methodmux
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// prefixHandler is a handler scoped to a path prefix.
+type prefixHandler struct {
+	prefix  string
+	handler http.Handler
+}
+
+// HandleNotFound registers the handler to use in place of
+// NotFoundHandler for the requests whose path starts with prefix. When
+// several prefixes apply, the longest wins. Like the patterns, prefix
+// matches regardless of case if CaseInsensitivePath is set.
+// If a handler already exists for prefix, HandleNotFound panics.
+func (mux *ServeMux) HandleNotFound(prefix string, handler http.Handler) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	if mux.CaseInsensitivePath {
+		prefix = lowerASCII(prefix)
+	}
+
+	for _, e := range mux.notFound {
+		if e.prefix == prefix {
+			panic("methodmux: multiple not-found handlers for prefix " + prefix)
+		}
+	}
+
+	mux.notFound = append(mux.notFound, prefixHandler{prefix: prefix, handler: handler})
+	sort.SliceStable(mux.notFound, func(i, j int) bool {
+		return len(mux.notFound[i].prefix) > len(mux.notFound[j].prefix)
+	})
+}
+
+// SPAFallback registers the handler to serve the GET and HEAD requests
+// that match no registered handler, typically the index page of a
+// single-page application. Requests with other methods are answered as
+// usual. Handlers registered with HandleNotFound take precedence.
+func (mux *ServeMux) SPAFallback(index http.Handler) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	mux.spa = index
+}
+
+// notFoundHandler returns the handler for the requests that match no
+// registered handler. The caller must hold the read lock.
+func (mux *ServeMux) notFoundHandler(method string, r *http.Request) http.Handler {
+	for _, e := range mux.notFound {
+		if strings.HasPrefix(r.URL.Path, e.prefix) {
+			return e.handler
+		}
+	}
+	if mux.spa != nil && (method == http.MethodGet || method == http.MethodHead) {
+		return mux.spa
+	}
+	if mux.NotFoundFallback != nil {
+		return mux.NotFoundFallback
+	}
+	return NotFoundHandler
+}
+
+// ReverseProxyFallback returns a handler that forwards the requests to
+// target, preserving their method and path, and relays the responses.
+// It is meant to be used as NotFoundFallback, to serve the routes that
+// mux does not know from a legacy backend.
+func ReverseProxyFallback(target *url.URL) http.Handler {
+	return httputil.NewSingleHostReverseProxy(target)
+}
@@ -0,0 +1,146 @@
+package methodmux_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	. "github.com/pierreprinetti/go-methodmux"
+)
+
+func TestHandleNotFound(t *testing.T) {
+	testCases := [...]struct {
+		method       string
+		path         string
+		expectedCode int
+	}{
+		{"GET", "/api/missing", 450},
+		{"GET", "/api/v2/missing", 451},
+		{"GET", "/other", 404},
+		{"GET", "/api/items", 200},
+		{"POST", "/api/items", 405},
+	}
+
+	mux := New()
+	mux.Handle("GET", "/api/items", serve(200))
+	mux.HandleNotFound("/api/", serve(450))
+	mux.HandleNotFound("/api/v2/", serve(451))
+
+	for _, tc := range testCases {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest(tc.method, tc.path, nil))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+
+	t.Run("panics on duplicate prefix", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected HandleNotFound to panic")
+			}
+		}()
+		mux.HandleNotFound("/api/", http.NotFoundHandler())
+	})
+
+	t.Run("case-insensitive path", func(t *testing.T) {
+		mux := New()
+		mux.CaseInsensitivePath = true
+		mux.HandleNotFound("/Admin/", serve(450))
+
+		for _, path := range [...]string{"/admin/missing", "/ADMIN/missing", "/Admin/missing"} {
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest("GET", path, nil))
+			if want, have := 450, rw.Code; have != want {
+				t.Errorf("%s: expected status code %d, found %d", path, want, have)
+			}
+		}
+	})
+}
+
+func TestSPAFallback(t *testing.T) {
+	testCases := [...]struct {
+		method       string
+		path         string
+		expectedCode int
+	}{
+		{"GET", "/deep/route", 299},
+		{"HEAD", "/deep/route", 299},
+		{"POST", "/deep/route", 404},
+		{"GET", "/api/missing", 450},
+		{"GET", "/api/items", 200},
+		{"GET", "/api/create", 405},
+	}
+
+	mux := New()
+	mux.Handle("GET", "/api/items", serve(200))
+	mux.Handle("POST", "/api/create", serve(201))
+	mux.HandleNotFound("/api/", serve(450))
+	mux.SPAFallback(serve(299))
+
+	for _, tc := range testCases {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest(tc.method, tc.path, nil))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+}
+
+func TestNotFoundFallback(t *testing.T) {
+	mux := New()
+	mux.Handle("GET", "/items", serve(200))
+	mux.NotFoundFallback = serve(499)
+
+	testCases := [...]struct {
+		method       string
+		path         string
+		expectedCode int
+	}{
+		{"GET", "/items", 200},
+		{"POST", "/items", 405},
+		{"GET", "/missing", 499},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest(tc.method, tc.path, nil))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+}
+
+func TestReverseProxyFallback(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(299)
+		fmt.Fprintf(w, "%s %s", r.Method, r.URL.Path)
+	}))
+	defer upstream.Close()
+
+	target, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mux := New()
+	mux.Handle("GET", "/items", serve(200))
+	mux.NotFoundFallback = ReverseProxyFallback(target)
+
+	rw := httptest.NewRecorder()
+	mux.ServeHTTP(rw, httptest.NewRequest("DELETE", "/legacy/path", nil))
+	if want, have := 299, rw.Code; have != want {
+		t.Errorf("expected status code %d, found %d", want, have)
+	}
+	if want, have := "DELETE /legacy/path", rw.Body.String(); have != want {
+		t.Errorf("expected body %q, found %q", want, have)
+	}
+}
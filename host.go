@@ -0,0 +1,35 @@
+package methodmux
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// HostDispatcher returns a handler that serves each request with the
+// mux in byHost for its host, ignoring the port. Hosts are compared
+// case-insensitively. The requests for any other host are served by
+// def or, if def is nil, answered with NotFoundHandler.
+func HostDispatcher(byHost map[string]*ServeMux, def *ServeMux) http.Handler {
+	muxes := make(map[string]*ServeMux, len(byHost))
+	for host, mux := range byHost {
+		muxes[strings.ToLower(host)] = mux
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		if mux, ok := muxes[strings.ToLower(host)]; ok {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		if def != nil {
+			def.ServeHTTP(w, r)
+			return
+		}
+		NotFoundHandler.ServeHTTP(w, r)
+	})
+}
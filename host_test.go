@@ -0,0 +1,44 @@
+package methodmux_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/pierreprinetti/go-methodmux"
+)
+
+func TestHostDispatcher(t *testing.T) {
+	shop, blog, def := New(), New(), New()
+	shop.Handle("GET", "/", serve(201))
+	blog.Handle("GET", "/", serve(202))
+	def.Handle("GET", "/", serve(203))
+
+	testCases := [...]struct {
+		host         string
+		def          *ServeMux
+		expectedCode int
+	}{
+		{"shop.example.com", def, 201},
+		{"blog.example.com:8080", def, 202},
+		{"BLOG.example.com", def, 202},
+		{"other.example.com", def, 203},
+		{"other.example.com", nil, 404},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.host, func(t *testing.T) {
+			h := HostDispatcher(map[string]*ServeMux{
+				"shop.example.com": shop,
+				"blog.example.com": blog,
+			}, tc.def)
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Host = tc.host
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, req)
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package methodmux
+
+import (
+	"net/http"
+)
+
+// patternIndex holds the patterns registered with Handle for any
+// method, in a single http.ServeMux. A request that it does not match
+// is not matched by any method, which spares the per-method lookups
+// when looking for a 405. m is nil if the patterns of different
+// methods conflict with one another, as "/items/{id}" and
+// "/items/{name}" do under the Go 1.22 pattern rules: the per-method
+// lookups are then always performed.
+type patternIndex struct {
+	m *http.ServeMux
+}
+
+// buildIndex returns the index of the patterns of all methods. The
+// caller must hold the read lock.
+func (mux *ServeMux) buildIndex() (index *patternIndex) {
+	defer func() {
+		if recover() != nil {
+			index = &patternIndex{}
+		}
+	}()
+
+	m := http.NewServeMux()
+	seen := make(map[string]bool)
+	for _, patterns := range mux.routes {
+		for pattern := range patterns {
+			if !seen[pattern] {
+				seen[pattern] = true
+				m.Handle(pattern, NotFoundHandler)
+			}
+		}
+	}
+	return &patternIndex{m: m}
+}
+
+// crossMethodIndex returns the index of the patterns of all methods,
+// building it if needed, or nil if it is not usable: handlers may have
+// been registered out of its sight with MethodMux, the patterns may
+// conflict, or Canonicalize may match paths that the index, which
+// cleans them like http.ServeMux, does not. The caller must hold the
+// read lock.
+func (mux *ServeMux) crossMethodIndex() *http.ServeMux {
+	if mux.unindexed.Load() || mux.Canonicalize != nil {
+		return nil
+	}
+	index := mux.index.Load()
+	if index == nil {
+		index = mux.buildIndex()
+		mux.index.Store(index)
+	}
+	return index.m
+}
+
+// Build precomputes the index that the mux uses to tell quickly whether
+// a request that no handler registered for its method matches is
+// matched by another method. The index is otherwise built by the first
+// such request after each registration; calling Build once all the
+// handlers are registered keeps that cost off the first requests.
+func (mux *ServeMux) Build() {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	mux.index.Store(mux.buildIndex())
+}
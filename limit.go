@@ -0,0 +1,365 @@
+package methodmux
+
+import (
+	"errors"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HandleMaxBody registers the handler for the given method and pattern,
+// limiting the size of the request bodies it reads to maxBytes. Reading
+// past the limit returns an error to the handler, and makes the server
+// close the connection; see http.MaxBytesReader. If the handler writes
+// nothing after reading past the limit, the response is a 413 "Request
+// Entity Too Large", written on its behalf.
+func (mux *ServeMux) HandleMaxBody(method, pattern string, maxBytes int64, handler http.Handler) {
+	mux.Handle(method, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		mw := &maxBodyWriter{ResponseWriter: w}
+		r2 := new(http.Request)
+		*r2 = *r
+		r2.Body = &maxBody{ReadCloser: http.MaxBytesReader(w, r.Body, maxBytes), w: mw}
+
+		handler.ServeHTTP(mw, r2)
+
+		if mw.tooLarge && !mw.wroteHeader {
+			http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		}
+	}))
+}
+
+// maxBodyWriter is an http.ResponseWriter that records whether the
+// handler of HandleMaxBody has written a response.
+type maxBodyWriter struct {
+	http.ResponseWriter
+	tooLarge    bool
+	wroteHeader bool
+}
+
+func (w *maxBodyWriter) WriteHeader(code int) {
+	if code >= 200 {
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *maxBodyWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying ResponseWriter, for use by
+// http.ResponseController.
+func (w *maxBodyWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// maxBody is a request body that reports reads past the limit of
+// http.MaxBytesReader to w.
+type maxBody struct {
+	io.ReadCloser
+	w *maxBodyWriter
+}
+
+func (b *maxBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		b.w.tooLarge = true
+	}
+	return n, err
+}
+
+// BreakerOpts configures the circuit breaker of HandleBreaker.
+type BreakerOpts struct {
+	// Threshold is the number of consecutive 5xx responses that opens
+	// the breaker. If zero, 5 is used.
+	Threshold int
+
+	// Cooldown is how long the breaker stays open. If zero, 30 seconds
+	// are used.
+	Cooldown time.Duration
+}
+
+// HandleBreaker registers the handler for the given method and pattern,
+// behind a circuit breaker. After opts.Threshold consecutive responses
+// with a 5xx status code, the breaker opens and the requests are
+// answered with 503 "Service Unavailable" without calling the handler.
+// Once opts.Cooldown has elapsed, requests reach the handler again: a
+// successful response closes the breaker, while a single 5xx response
+// opens it for another cooldown period.
+func (mux *ServeMux) HandleBreaker(method, pattern string, handler http.Handler, opts BreakerOpts) {
+	if opts.Threshold == 0 {
+		opts.Threshold = 5
+	}
+	if opts.Cooldown == 0 {
+		opts.Cooldown = 30 * time.Second
+	}
+
+	var (
+		mu        sync.Mutex
+		failures  int
+		openUntil time.Time
+	)
+
+	mux.Handle(method, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		open := time.Now().Before(openUntil)
+		mu.Unlock()
+
+		if open {
+			ServiceUnavailableHandler.ServeHTTP(w, r)
+			return
+		}
+
+		sw := &statusWriter{ResponseWriter: w}
+		handler.ServeHTTP(sw.withOptional(), r)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if sw.code() < 500 {
+			failures = 0
+			return
+		}
+		if failures++; failures >= opts.Threshold {
+			openUntil = time.Now().Add(opts.Cooldown)
+		}
+	}))
+}
+
+// HandleReadTimeout registers the handler for the given method and
+// pattern, bounding to d the time it can spend reading the request body.
+// The deadline is set on the underlying connection with
+// http.ResponseController; if the ResponseWriter does not support read
+// deadlines, the handler is called without one.
+//
+// When reading the body times out, the response is a 408 "Request
+// Timeout": the status code written by the handler, if any, is replaced,
+// and if the handler writes nothing, the 408 is written on its behalf.
+func (mux *ServeMux) HandleReadTimeout(method, pattern string, d time.Duration, handler http.Handler) {
+	mux.Handle(method, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		if err := rc.SetReadDeadline(time.Now().Add(d)); err != nil {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		defer rc.SetReadDeadline(time.Time{})
+
+		tw := &timeoutWriter{ResponseWriter: w}
+		if r.Body != nil {
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.Body = &timeoutBody{ReadCloser: r.Body, w: tw}
+			r = r2
+		}
+
+		handler.ServeHTTP(tw, r)
+
+		if tw.timedOut && !tw.wroteHeader {
+			http.Error(w, http.StatusText(http.StatusRequestTimeout), http.StatusRequestTimeout)
+		}
+	}))
+}
+
+// timeoutWriter is an http.ResponseWriter that replaces the status code
+// with 408 once the request body has timed out.
+type timeoutWriter struct {
+	http.ResponseWriter
+	timedOut    bool
+	wroteHeader bool
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	if w.timedOut && code >= 200 {
+		code = http.StatusRequestTimeout
+	}
+	if code >= 200 {
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying ResponseWriter, for use by
+// http.ResponseController.
+func (w *timeoutWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// timeoutBody is a request body that reports read timeouts to w.
+type timeoutBody struct {
+	io.ReadCloser
+	w *timeoutWriter
+}
+
+func (b *timeoutBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && isTimeout(err) {
+		b.w.timedOut = true
+	}
+	return n, err
+}
+
+// isTimeout reports whether err is caused by an exceeded deadline.
+func isTimeout(err error) bool {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// HandleLimited registers the handler for the given method and pattern,
+// rate limited to rps requests per second with bursts of up to burst
+// requests, across all clients. Requests over the limit are answered
+// with 429 "Too Many Requests".
+//
+// HandleLimited panics if rps is not positive or burst is less than 1.
+func (mux *ServeMux) HandleLimited(method, pattern string, rps float64, burst int, handler http.Handler) {
+	mux.HandleLimitedBy(method, pattern, rps, burst, handler, func(*http.Request) string { return "" })
+}
+
+// HandleLimitedBy is like HandleLimited, but applies the limit
+// separately to each key returned by keyFn, such as the client address.
+func (mux *ServeMux) HandleLimitedBy(method, pattern string, rps float64, burst int, handler http.Handler, keyFn func(*http.Request) string) {
+	if !(rps > 0) {
+		panic("methodmux: non-positive rate limit " + strconv.FormatFloat(rps, 'g', -1, 64))
+	}
+	if burst < 1 {
+		panic("methodmux: rate limit burst less than 1: " + strconv.Itoa(burst))
+	}
+
+	l := &limiter{
+		rps:     rps,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+	}
+	retryAfter := strconv.Itoa(int(math.Ceil(1 / rps)))
+
+	mux.Handle(method, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !l.allow(keyFn(r), time.Now()) {
+			w.Header().Set("Retry-After", retryAfter)
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+}
+
+// limiter is a set of token buckets, one per key.
+type limiter struct {
+	rps, burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// allow takes a token from the bucket of key, and reports whether one
+// was available.
+func (l *limiter) allow(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, exists := l.buckets[key]
+	if !exists {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rps)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// sweep forgets the buckets that have refilled completely, as they are
+// indistinguishable from new ones, once per refill period.
+func (l *limiter) sweep(now time.Time) {
+	refill := time.Duration(l.burst / l.rps * float64(time.Second))
+	if now.Sub(l.lastSweep) < refill {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= refill {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// HandleShed registers the handler for the given method and pattern,
+// serving at most maxInflight requests at a time. The requests over
+// the limit are answered with 503 "Service Unavailable" and a
+// Retry-After header, rather than queued.
+func (mux *ServeMux) HandleShed(method, pattern string, maxInflight int64, handler http.Handler) {
+	var inflight atomic.Int64
+
+	mux.Handle(method, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer inflight.Add(-1)
+		if inflight.Add(1) > maxInflight {
+			w.Header().Set("Retry-After", "1")
+			ServiceUnavailableHandler.ServeHTTP(w, r)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+}
+
+// HandleCancelOnDisconnect registers the handler for the given method
+// and pattern, serving each request in a separate goroutine. If the
+// request context is done before the handler returns, as when the
+// client disconnects, the request is counted in Stats.Disconnects and
+// the handler is not waited for: ServeHTTP returns right away. The
+// handler keeps running until it returns on its own, which it should
+// do once its context is done; from then on, its writes are discarded.
+// The header map that w.Header returned before must not be modified
+// once the context is done.
+func (mux *ServeMux) HandleCancelOnDisconnect(method, pattern string, handler http.Handler) {
+	mux.Handle(method, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dw := &detachableWriter{ResponseWriter: w}
+		panicked := make(chan interface{}, 1)
+
+		go func() {
+			defer func() { panicked <- recover() }()
+			handler.ServeHTTP(dw, r)
+		}()
+
+		select {
+		case p := <-panicked:
+			if p != nil {
+				panic(p)
+			}
+		case <-r.Context().Done():
+			dw.detach()
+			mux.stats.disconnects.Add(1)
+		}
+	}))
+}
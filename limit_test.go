@@ -0,0 +1,346 @@
+package methodmux_test
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/pierreprinetti/go-methodmux"
+)
+
+func TestHandleMaxBody(t *testing.T) {
+	testCases := [...]struct {
+		name          string
+		body          string
+		expectedError bool
+	}{
+		{"within the limit", "0123456789", false},
+		{"over the limit", "0123456789a", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				body    []byte
+				readErr error
+			)
+			mux := New()
+			mux.HandleMaxBody("POST", "/upload", 10, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, readErr = io.ReadAll(r.Body)
+			}))
+
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest("POST", "/upload", strings.NewReader(tc.body)))
+			if tc.expectedError {
+				if _, ok := readErr.(*http.MaxBytesError); !ok {
+					t.Errorf("expected a *http.MaxBytesError, found %v", readErr)
+				}
+				if want, have := 413, rw.Code; have != want {
+					t.Errorf("expected status code %d, found %d", want, have)
+				}
+			} else {
+				if readErr != nil {
+					t.Errorf("unexpected error: %v", readErr)
+				}
+				if want, have := tc.body, string(body); have != want {
+					t.Errorf("expected body %q, found %q", want, have)
+				}
+				if want, have := 200, rw.Code; have != want {
+					t.Errorf("expected status code %d, found %d", want, have)
+				}
+			}
+		})
+	}
+
+	t.Run("keeps the response of the handler", func(t *testing.T) {
+		mux := New()
+		mux.HandleMaxBody("POST", "/upload", 10, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, err := io.ReadAll(r.Body); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		}))
+
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, httptest.NewRequest("POST", "/upload", strings.NewReader("0123456789a")))
+		if want, have := 400, rw.Code; have != want {
+			t.Errorf("expected status code %d, found %d", want, have)
+		}
+	})
+}
+
+func TestHandleBreaker(t *testing.T) {
+	const cooldown = 50 * time.Millisecond
+
+	var code int32
+	mux := New()
+	mux.HandleBreaker("GET", "/flaky", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(atomic.LoadInt32(&code)))
+	}), BreakerOpts{Threshold: 3, Cooldown: cooldown})
+
+	expect := func(t *testing.T, want int) {
+		t.Helper()
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, httptest.NewRequest("GET", "/flaky", nil))
+		if have := rw.Code; have != want {
+			t.Errorf("expected status code %d, found %d", want, have)
+		}
+	}
+
+	atomic.StoreInt32(&code, 500)
+	expect(t, 500)
+	expect(t, 500)
+
+	atomic.StoreInt32(&code, 200)
+	expect(t, 200)
+
+	t.Run("trips after consecutive failures", func(t *testing.T) {
+		atomic.StoreInt32(&code, 502)
+		expect(t, 502)
+		expect(t, 502)
+		expect(t, 502)
+
+		atomic.StoreInt32(&code, 200)
+		expect(t, 503)
+	})
+
+	t.Run("reopens on failure after the cooldown", func(t *testing.T) {
+		time.Sleep(2 * cooldown)
+		atomic.StoreInt32(&code, 500)
+		expect(t, 500)
+		expect(t, 503)
+	})
+
+	t.Run("recovers after the cooldown", func(t *testing.T) {
+		time.Sleep(2 * cooldown)
+		atomic.StoreInt32(&code, 200)
+		expect(t, 200)
+		atomic.StoreInt32(&code, 500)
+		expect(t, 500)
+		expect(t, 500)
+		atomic.StoreInt32(&code, 200)
+		expect(t, 200)
+	})
+}
+
+func TestHandleReadTimeout(t *testing.T) {
+	mux := New()
+	mux.HandleReadTimeout("POST", "/upload", 50*time.Millisecond, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(201)
+	}))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	testCases := [...]struct {
+		name         string
+		delay        time.Duration
+		expectedCode int
+	}{
+		{"fast body", 0, 201},
+		{"slow body", 500 * time.Millisecond, 408},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			pr, pw := io.Pipe()
+			go func() {
+				pw.Write([]byte("some"))
+				time.Sleep(tc.delay)
+				pw.Write([]byte("body"))
+				pw.Close()
+			}()
+
+			res, err := http.Post(srv.URL+"/upload", "text/plain", pr)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			res.Body.Close()
+			if want, have := tc.expectedCode, res.StatusCode; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+}
+
+func TestHandleLimited(t *testing.T) {
+	mux := New()
+	mux.HandleLimited("GET", "/limited", 0.1, 2, serve(200))
+	mux.HandleLimitedBy("GET", "/by-client", 0.1, 1, serve(200), func(r *http.Request) string {
+		return r.Header.Get("X-Client")
+	})
+
+	expect := func(t *testing.T, path, client string, want int) {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-Client", client)
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, req)
+		if have := rw.Code; have != want {
+			t.Errorf("GET %s as %q: expected status code %d, found %d", path, client, want, have)
+		}
+		if want == 429 && rw.Header().Get("Retry-After") != "10" {
+			t.Errorf("expected \"Retry-After: 10\", found %q", rw.Header().Get("Retry-After"))
+		}
+	}
+
+	t.Run("per route", func(t *testing.T) {
+		expect(t, "/limited", "a", 200)
+		expect(t, "/limited", "b", 200)
+		expect(t, "/limited", "c", 429)
+	})
+
+	t.Run("per client", func(t *testing.T) {
+		expect(t, "/by-client", "a", 200)
+		expect(t, "/by-client", "a", 429)
+		expect(t, "/by-client", "b", 200)
+		expect(t, "/by-client", "b", 429)
+	})
+
+	for _, tc := range [...]struct {
+		name  string
+		rps   float64
+		burst int
+	}{
+		{"zero rate", 0, 1},
+		{"negative rate", -1, 1},
+		{"NaN rate", math.NaN(), 1},
+		{"zero burst", 1, 0},
+	} {
+		t.Run("panics with "+tc.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected HandleLimited to panic")
+				}
+			}()
+			New().HandleLimited("GET", "/", tc.rps, tc.burst, serve(200))
+		})
+	}
+}
+
+func TestHandleShed(t *testing.T) {
+	const maxInflight = 3
+
+	var entered sync.WaitGroup
+	release := make(chan struct{})
+
+	mux := New()
+	mux.HandleShed("GET", "/slow", maxInflight, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("panic") != "" {
+			panic("handler failure")
+		}
+		entered.Done()
+		<-release
+	}))
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, httptest.NewRequest("GET", path, nil))
+		return rw
+	}
+
+	t.Run("panic", func(t *testing.T) {
+		for i := 0; i < 2*maxInflight; i++ {
+			func() {
+				defer func() { recover() }()
+				get("/slow?panic=1")
+			}()
+		}
+	})
+
+	t.Run("over the limit", func(t *testing.T) {
+		var done sync.WaitGroup
+		entered.Add(maxInflight)
+		done.Add(maxInflight)
+		for i := 0; i < maxInflight; i++ {
+			go func() {
+				defer done.Done()
+				get("/slow")
+			}()
+		}
+		entered.Wait()
+
+		rw := get("/slow")
+		if want, have := 503, rw.Code; have != want {
+			t.Errorf("expected status code %d, found %d", want, have)
+		}
+		if rw.Header().Get("Retry-After") == "" {
+			t.Errorf("expected a Retry-After header")
+		}
+
+		close(release)
+		done.Wait()
+
+		entered.Add(1)
+		if want, have := 200, get("/slow").Code; have != want {
+			t.Errorf("expected status code %d, found %d", want, have)
+		}
+	})
+}
+
+func TestHandleCancelOnDisconnect(t *testing.T) {
+	release := make(chan struct{})
+	written := make(chan error, 1)
+
+	mux := New()
+	mux.HandleCancelOnDisconnect("GET", "/slow", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("wait") != "" {
+			<-release
+		}
+		_, err := io.WriteString(w, "done")
+		written <- err
+	}))
+
+	t.Run("completed", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, httptest.NewRequest("GET", "/slow", nil))
+		if err := <-written; err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if want, have := "done", rw.Body.String(); have != want {
+			t.Errorf("expected body %q, found %q", want, have)
+		}
+	})
+
+	t.Run("disconnected", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, httptest.NewRequest("GET", "/slow?wait=1", nil).WithContext(ctx))
+		if want, have := uint64(1), mux.Stats().Disconnects; have != want {
+			t.Errorf("expected %d disconnects, found %d", want, have)
+		}
+
+		close(release)
+		if err := <-written; err == nil {
+			t.Errorf("expected the write after the disconnection to fail")
+		}
+		if rw.Body.Len() != 0 {
+			t.Errorf("expected an empty body, found %q", rw.Body.String())
+		}
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		mux := New()
+		mux.HandleCancelOnDisconnect("GET", "/panic", http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			panic("handler failure")
+		}))
+
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected the panic to be propagated")
+			}
+		}()
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/panic", nil))
+	})
+}
@@ -0,0 +1,71 @@
+package methodmux
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WithAccessLog returns a handler that serves the requests with mux,
+// writing a line to w for each of them, in the format:
+//
+//	method path pattern status bytes duration
+//
+// where pattern is "-" if no pattern matched, bytes is the size of the
+// response body and duration is the time spent serving the request.
+// Lines are written one at a time, even if w is shared.
+func (mux *ServeMux) WithAccessLog(w io.Writer) http.Handler {
+	var mu sync.Mutex
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		_, pattern := mux.Handler(r)
+		if pattern == "" {
+			pattern = "-"
+		}
+
+		sw := &statusWriter{ResponseWriter: rw}
+		mux.ServeHTTP(sw.withOptional(), r)
+
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprintf(w, "%s %s %s %d %d %s\n", r.Method, r.URL.RequestURI(), pattern, sw.code(), sw.bytes, time.Since(start))
+	})
+}
+
+// HandleTee registers the handler for the given method and pattern,
+// copying the body of each request to sink before calling the handler.
+// The body is read in full, and the handler is called with a copy of
+// the request holding an in-memory copy of the body, so that it reads
+// it unchanged; its ContentLength is set to the size of the body.
+// Writes to sink happen one request at a time, even if it is shared.
+// As each body is held in memory, HandleTee is meant for debugging.
+func (mux *ServeMux) HandleTee(method, pattern string, handler http.Handler, sink io.Writer) {
+	var mu sync.Mutex
+
+	mux.Handle(method, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil && r.Body != http.NoBody {
+			body, err := io.ReadAll(r.Body)
+			r.Body.Close()
+			if err != nil {
+				BadRequestHandler.ServeHTTP(w, r)
+				return
+			}
+
+			mu.Lock()
+			sink.Write(body)
+			mu.Unlock()
+
+			r2 := new(http.Request)
+			*r2 = *r
+			r2.Body = io.NopCloser(bytes.NewReader(body))
+			r2.ContentLength = int64(len(body))
+			r = r2
+		}
+		handler.ServeHTTP(w, r)
+	}))
+}
@@ -0,0 +1,91 @@
+package methodmux_test
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/pierreprinetti/go-methodmux"
+)
+
+func TestWithAccessLog(t *testing.T) {
+	testCases := [...]struct {
+		method         string
+		path           string
+		expectedFields []string
+	}{
+		{"GET", "/items/42", []string{"GET", "/items/42", "/items/", "200", "4"}},
+		{"POST", "/items/42?a=1", []string{"POST", "/items/42?a=1", "-", "405", "19"}},
+	}
+
+	mux := New()
+	mux.HandleFunc("GET", "/items/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("item"))
+	})
+
+	for _, tc := range testCases {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			var buf bytes.Buffer
+			mux.WithAccessLog(&buf).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tc.method, tc.path, nil))
+
+			line := buf.String()
+			if !strings.HasSuffix(line, "\n") {
+				t.Fatalf("expected a complete line, found %q", line)
+			}
+			fields := strings.Fields(line)
+			if len(fields) != 6 {
+				t.Fatalf("expected 6 fields, found %q", line)
+			}
+			for i, want := range tc.expectedFields {
+				if have := fields[i]; have != want {
+					t.Errorf("expected field %d to be %q, found %q", i, want, have)
+				}
+			}
+			if _, err := time.ParseDuration(fields[5]); err != nil {
+				t.Errorf("expected a duration, found %q", fields[5])
+			}
+		})
+	}
+}
+
+func TestHandleTee(t *testing.T) {
+	const payload = `{"name":"widget"}`
+
+	var sink bytes.Buffer
+	var received string
+	var contentLength int64
+
+	mux := New()
+	mux.HandleTee("POST", "/items", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		received, contentLength = string(b), r.ContentLength
+		w.WriteHeader(201)
+	}), &sink)
+
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/items", strings.NewReader(payload))
+	body := req.Body
+	mux.ServeHTTP(rw, req)
+	if want, have := 201, rw.Code; have != want {
+		t.Errorf("expected status code %d, found %d", want, have)
+	}
+	if req.Body != body {
+		t.Errorf("expected the body of the request to be left untouched")
+	}
+	if want, have := payload, sink.String(); have != want {
+		t.Errorf("expected the sink to receive %q, found %q", want, have)
+	}
+	if want, have := payload, received; have != want {
+		t.Errorf("expected the handler to receive %q, found %q", want, have)
+	}
+	if want, have := int64(len(payload)), contentLength; have != want {
+		t.Errorf("expected Content-Length %d, found %d", want, have)
+	}
+}
@@ -0,0 +1,173 @@
+package methodmux
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+var (
+	// SafeMethods are the methods that are not expected to change the
+	// state of the server. They must not be modified.
+	SafeMethods = []string{http.MethodGet, http.MethodHead, http.MethodOptions}
+
+	// IdempotentMethods are the methods whose intended effect on the
+	// server is the same for one or several identical requests. They
+	// must not be modified.
+	IdempotentMethods = []string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete}
+
+	// AllMethods are the methods defined in net/http. They must not be
+	// modified.
+	AllMethods = []string{
+		http.MethodGet,
+		http.MethodHead,
+		http.MethodPost,
+		http.MethodPut,
+		http.MethodPatch,
+		http.MethodDelete,
+		http.MethodConnect,
+		http.MethodOptions,
+		http.MethodTrace,
+	}
+)
+
+// Method is an HTTP method, for registering handlers with HandleM.
+// The constants cover the methods defined in net/http; other methods
+// can be converted, as in Method("PROPFIND").
+type Method string
+
+// The methods defined in net/http.
+const (
+	GET     Method = http.MethodGet
+	HEAD    Method = http.MethodHead
+	POST    Method = http.MethodPost
+	PUT     Method = http.MethodPut
+	PATCH   Method = http.MethodPatch
+	DELETE  Method = http.MethodDelete
+	CONNECT Method = http.MethodConnect
+	OPTIONS Method = http.MethodOptions
+	TRACE   Method = http.MethodTrace
+)
+
+// HandleM registers the handler for the given method and pattern, like
+// Handle. Taking a Method makes a typo in the name of a constant a
+// compile-time error.
+func (mux *ServeMux) HandleM(m Method, pattern string, handler http.Handler) {
+	mux.Handle(string(m), pattern, handler)
+}
+
+// HandleSet registers the handler for each method of the given set, for
+// the given pattern. Like Handle, it panics if a handler already exists
+// for any of the combinations of method and pattern.
+func (mux *ServeMux) HandleSet(set []string, pattern string, handler http.Handler) {
+	for _, method := range set {
+		mux.Handle(method, pattern, handler)
+	}
+}
+
+// HandleAllMethods registers the handler for every method of
+// AllMethods, for the given pattern. It is meant for handlers that
+// dispatch on the method themselves. Requests with other methods, such
+// as WebDAV extensions, are not routed to the handler.
+func (mux *ServeMux) HandleAllMethods(pattern string, handler http.Handler) {
+	mux.HandleSet(AllMethods, pattern, handler)
+}
+
+// HandleFuncMethods registers the handler function for each of the
+// given methods, for the given pattern. It panics if no method is
+// given and, like Handle, if a handler already exists for any of the
+// combinations of method and pattern.
+func (mux *ServeMux) HandleFuncMethods(pattern string, handler func(http.ResponseWriter, *http.Request), methods ...string) {
+	if len(methods) == 0 {
+		panic("methodmux: no methods for " + pattern)
+	}
+	mux.HandleSet(methods, pattern, http.HandlerFunc(handler))
+}
+
+// AliasMethod makes mux resolve the requests with method alias as if
+// their method was canonical, such as "READ" as "GET". Handlers are
+// registered with the canonical method; the request itself is not
+// modified.
+func (mux *ServeMux) AliasMethod(alias, canonical string) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	if mux.aliases == nil {
+		mux.aliases = make(map[string]string)
+	}
+	mux.aliases[alias] = canonical
+}
+
+// methodPrefix holds the handlers registered with HandleMethodPrefix
+// for a method prefix.
+type methodPrefix struct {
+	prefix string
+	m      *http.ServeMux
+	routes map[string]*route
+}
+
+// HandleMethodPrefix registers the handler for the given pattern and
+// every method starting with prefix, such as "PROPFIND" and
+// "PROPPATCH" for "PROP". The handlers registered with Handle for the
+// exact method of a request take precedence. When several prefixes
+// apply, the longest wins. Requests with other methods are not
+// answered with 405 because of these handlers.
+//
+// OnRegister and Routes see these handlers with the method prefix
+// followed by "*", such as "PROP*".
+func (mux *ServeMux) HandleMethodPrefix(prefix, pattern string, handler http.Handler) {
+	method, pattern := mux.onRegister(prefix+"*", pattern)
+	prefix = strings.TrimSuffix(method, "*")
+
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	if handler == nil {
+		panic("methodmux: nil handler")
+	}
+
+	if err := mux.checkPattern(pattern); err != nil {
+		panic(err.Error())
+	}
+	pattern = mux.foldPattern(pattern)
+
+	e := mux.methodPrefixFor(prefix)
+	if _, exists := e.routes[pattern]; exists {
+		panic("methodmux: multiple registrations for " + prefix + "* " + pattern)
+	}
+	rt := &route{handler: handler}
+	e.m.Handle(pattern, rt)
+	e.routes[pattern] = rt
+}
+
+// methodPrefixFor returns the handlers registered for prefix, adding
+// them if needed. The caller must hold the write lock.
+func (mux *ServeMux) methodPrefixFor(prefix string) methodPrefix {
+	for _, e := range mux.methodPrefixes {
+		if e.prefix == prefix {
+			return e
+		}
+	}
+
+	e := methodPrefix{prefix: prefix, m: http.NewServeMux(), routes: make(map[string]*route)}
+	mux.methodPrefixes = append(mux.methodPrefixes, e)
+	sort.SliceStable(mux.methodPrefixes, func(i, j int) bool {
+		return len(mux.methodPrefixes[i].prefix) > len(mux.methodPrefixes[j].prefix)
+	})
+	return e
+}
+
+// matchMethodPrefix returns the match for r among the handlers
+// registered with HandleMethodPrefix for the prefixes of method. If
+// none matches, the returned pattern is empty. The caller must hold the
+// read lock.
+func (mux *ServeMux) matchMethodPrefix(method string, r *http.Request) match {
+	for _, e := range mux.methodPrefixes {
+		if strings.HasPrefix(method, e.prefix) {
+			if match := mux.lookup(e.m, r); match.pattern != "" {
+				return match
+			}
+		}
+	}
+	return match{}
+}
@@ -0,0 +1,295 @@
+package methodmux_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	. "github.com/pierreprinetti/go-methodmux"
+)
+
+func TestHandleSet(t *testing.T) {
+	testCases := [...]struct {
+		method       string
+		expectedCode int
+	}{
+		{"GET", 200},
+		{"HEAD", 200},
+		{"OPTIONS", 200},
+		{"POST", 405},
+		{"DELETE", 405},
+	}
+
+	mux := New()
+	mux.HandleSet(SafeMethods, "/resource", serve(200))
+
+	for _, tc := range testCases {
+		t.Run(tc.method, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest(tc.method, "/resource", nil))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+}
+
+func TestHandleAllMethods(t *testing.T) {
+	testCases := [...]struct {
+		method       string
+		expectedCode int
+	}{
+		{"GET", 200},
+		{"POST", 200},
+		{"DELETE", 200},
+		{"PROPFIND", 405},
+	}
+
+	mux := New()
+	mux.HandleAllMethods("/gateway/", serve(200))
+
+	for _, tc := range testCases {
+		t.Run(tc.method, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest(tc.method, "/gateway/service", nil))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+}
+
+func TestHandleFuncMethods(t *testing.T) {
+	testCases := [...]struct {
+		method       string
+		expectedCode int
+	}{
+		{"GET", 200},
+		{"POST", 200},
+		{"PUT", 405},
+	}
+
+	mux := New()
+	mux.HandleFuncMethods("/items", serve(200), "GET", "POST")
+
+	for _, tc := range testCases {
+		t.Run(tc.method, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest(tc.method, "/items", nil))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+
+	t.Run("no methods", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected a panic")
+			}
+		}()
+		New().HandleFuncMethods("/items", serve(200))
+	})
+}
+
+func TestAliasMethod(t *testing.T) {
+	testCases := [...]struct {
+		method       string
+		expectedCode int
+	}{
+		{"READ", 200},
+		{"GET", 200},
+		{"WRITE", 201},
+		{"DELETE", 405},
+	}
+
+	mux := New()
+	mux.AliasMethod("READ", "GET")
+	mux.AliasMethod("WRITE", "POST")
+	mux.Handle("GET", "/items", serve(200))
+	mux.Handle("POST", "/items", serve(201))
+
+	for _, tc := range testCases {
+		t.Run(tc.method, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest(tc.method, "/items", nil))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+}
+
+func TestHandleMethodPrefix(t *testing.T) {
+	testCases := [...]struct {
+		method       string
+		path         string
+		expectedCode int
+	}{
+		{"PROPFIND", "/files/a", 207},
+		{"PROPPATCH", "/files/a", 207},
+		{"PROPDELETE", "/files/a", 208},
+		{"PROP", "/files/a", 207},
+		{"MKCOL", "/files/a", 405},
+		{"GET", "/files/a", 200},
+		{"POST", "/files/a", 405},
+		{"PROPFIND", "/other", 404},
+	}
+
+	mux := New()
+	mux.Handle("GET", "/files/", serve(200))
+	mux.HandleMethodPrefix("PROP", "/files/", serve(207))
+	mux.HandleMethodPrefix("PROPDEL", "/files/", serve(208))
+
+	for _, tc := range testCases {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest(tc.method, tc.path, nil))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+
+	t.Run("exact method wins", func(t *testing.T) {
+		mux.Handle("PROPFIND", "/files/", serve(201))
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, httptest.NewRequest("PROPFIND", "/files/a", nil))
+		if want, have := 201, rw.Code; have != want {
+			t.Errorf("expected status code %d, found %d", want, have)
+		}
+	})
+
+	t.Run("registers like Handle", func(t *testing.T) {
+		mux := New()
+		mux.StrictPatterns = true
+		var registered []string
+		mux.OnRegister = func(method, pattern string) (string, string, error) {
+			registered = append(registered, method+" "+pattern)
+			return method, pattern, nil
+		}
+		mux.HandleMethodPrefix("PROP", "/files/", serve(207))
+
+		if want, have := []string{"PROP* /files/"}, registered; !reflect.DeepEqual(have, want) {
+			t.Errorf("expected registrations %q, found %q", want, have)
+		}
+		routes := mux.Routes()
+		if len(routes) != 1 || routes[0].Method != "PROP*" || routes[0].Pattern != "/files/" {
+			t.Errorf("expected the route PROP* /files/, found %v", routes)
+		}
+		if want, have := 1, mux.Len(); have != want {
+			t.Errorf("expected %d routes, found %d", want, have)
+		}
+
+		for _, pattern := range [...]string{"files", "/files/"} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("%s: expected a panic", pattern)
+					}
+				}()
+				mux.HandleMethodPrefix("PROP", pattern, serve(207))
+			}()
+		}
+	})
+}
+
+func TestAutoHead(t *testing.T) {
+	const body = "twelve bytes"
+
+	testCases := [...]struct {
+		name                  string
+		autoHead              bool
+		path                  string
+		expectedCode          int
+		expectedContentLength string
+	}{
+		{"implicit length", true, "/implicit", 200, "12"},
+		{"explicit length", true, "/explicit", 200, "12"},
+		{"status", true, "/created", 201, "12"},
+		{"explicit HEAD handler", true, "/head", 204, ""},
+		{"disabled", false, "/implicit", 405, ""},
+	}
+
+	mux := func(autoHead bool) *ServeMux {
+		mux := New()
+		mux.AutoHead = autoHead
+		mux.HandleFunc("GET", "/implicit", func(w http.ResponseWriter, _ *http.Request) {
+			w.Write([]byte(body))
+		})
+		mux.HandleFunc("GET", "/explicit", func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Length", "12")
+			w.Write([]byte(body))
+		})
+		mux.HandleFunc("GET", "/created", func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(201)
+			w.Write([]byte(body))
+		})
+		mux.Handle("GET", "/head", serve(200))
+		mux.Handle("HEAD", "/head", serve(204))
+		return mux
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			mux(tc.autoHead).ServeHTTP(rw, httptest.NewRequest("HEAD", tc.path, nil))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+			if want, have := tc.expectedContentLength, rw.Header().Get("Content-Length"); have != want {
+				t.Errorf("expected Content-Length %q, found %q", want, have)
+			}
+			if tc.expectedCode != 405 && rw.Body.Len() != 0 {
+				t.Errorf("expected an empty body, found %q", rw.Body.String())
+			}
+		})
+	}
+
+	t.Run("Allow header", func(t *testing.T) {
+		for autoHead, want := range map[bool]string{true: "GET, HEAD", false: "GET"} {
+			rw := httptest.NewRecorder()
+			mux(autoHead).ServeHTTP(rw, httptest.NewRequest("POST", "/implicit", nil))
+			if want, have := 405, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+			if have := rw.Header().Get("Allow"); have != want {
+				t.Errorf("AutoHead=%t: expected Allow %q, found %q", autoHead, want, have)
+			}
+		}
+
+		rw := httptest.NewRecorder()
+		mux(true).ServeHTTP(rw, httptest.NewRequest("POST", "/head", nil))
+		if want, have := "GET, HEAD", rw.Header().Get("Allow"); have != want {
+			t.Errorf("expected Allow %q, found %q", want, have)
+		}
+	})
+}
+
+func TestHandleM(t *testing.T) {
+	testCases := [...]struct {
+		method       string
+		expectedCode int
+	}{
+		{"GET", 200},
+		{"DELETE", 204},
+		{"PROPFIND", 207},
+		{"POST", 405},
+	}
+
+	mux := New()
+	mux.HandleM(GET, "/x", serve(200))
+	mux.HandleM(DELETE, "/x", serve(204))
+	mux.HandleM(Method("PROPFIND"), "/x", serve(207))
+
+	for _, tc := range testCases {
+		t.Run(tc.method, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest(tc.method, "/x", nil))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+}
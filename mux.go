@@ -40,8 +40,18 @@ Methodmux has been written with readability in mind and is just as fast and effi
 package methodmux // import "github.com/pierreprinetti/go-methodmux"
 
 import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 var (
@@ -51,6 +61,12 @@ var (
 		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
 	})
 
+	// ForbiddenHandler is a http.Handler that replies to the request
+	// with an HTTP 403 "Forbidden" error.
+	ForbiddenHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+	})
+
 	// NotFoundHandler is a http.Handler that replies to the request with
 	// an HTTP 404 "Not Found" error.
 	NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
@@ -62,14 +78,317 @@ var (
 	MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
 		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
 	})
+
+	// MisdirectedRequestHandler is a http.Handler that replies to the
+	// request with an HTTP 421 "Misdirected Request" error.
+	MisdirectedRequestHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, http.StatusText(http.StatusMisdirectedRequest), http.StatusMisdirectedRequest)
+	})
+
+	// HTTPVersionNotSupportedHandler is a http.Handler that replies to
+	// the request with an HTTP 505 "HTTP Version Not Supported" error.
+	HTTPVersionNotSupportedHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, http.StatusText(http.StatusHTTPVersionNotSupported), http.StatusHTTPVersionNotSupported)
+	})
+
+	// ServiceUnavailableHandler is a http.Handler that replies to
+	// the request with an HTTP 503 "Service Unavailable" error.
+	ServiceUnavailableHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+	})
 )
 
+// Router is the read-only subset of the ServeMux API: it resolves and
+// serves requests, but does not allow registering handlers.
+type Router interface {
+	Handler(r *http.Request) (h http.Handler, pattern string)
+	ServeHTTP(w http.ResponseWriter, r *http.Request)
+}
+
 // ServeMux is a method-aware HTTP request multiplexer.
 // Every registered handler will be only served for the particular HTTP method
 // it has been registered with.
+//
+// The exported fields configure optional behaviour. They must be set
+// before the ServeMux starts serving requests.
+//
+// The methods of ServeMux are safe for concurrent use: handlers can be
+// registered, swapped and removed while requests are being served.
+// Each request is resolved against a consistent snapshot of the
+// registered handlers; a request resolved before a change is served
+// by the handler it resolved to, even if the change completes first.
+// The exported fields are not synchronized: changing them while
+// serving requests is a data race. Handlers registered directly to the
+// http.ServeMux returned by MethodMux bypass the synchronization.
 type ServeMux struct {
-	mu sync.RWMutex
-	m  map[string]*http.ServeMux
+	// NormalizeMethod makes the mux ignore any whitespace surrounding
+	// the request method, so that a request with method " GET " is
+	// routed to the handlers registered for "GET". The request itself
+	// is not modified.
+	NormalizeMethod bool
+
+	// DisableTrace makes ServeHTTP answer every TRACE request with a
+	// 405 "Method Not Allowed", even if a TRACE handler is registered.
+	DisableTrace bool
+
+	// RedirectCode is the status code of the redirects to the canonical
+	// path, such as "/dir" to "/dir/". If zero, 301 "Moved Permanently"
+	// is used, like http.ServeMux does.
+	RedirectCode int
+
+	// PreserveMethodOnRedirect makes the redirects to the canonical
+	// path use 308 "Permanent Redirect" instead of 301, so that clients
+	// repeat the request with the same method and body rather than
+	// switching to GET. RedirectCode, if set, takes precedence.
+	PreserveMethodOnRedirect bool
+
+	// ContextFunc, if set, is called by ServeHTTP before dispatching
+	// each request. The returned context replaces the request context;
+	// if it is nil, the request context is left untouched.
+	ContextFunc func(r *http.Request) context.Context
+
+	// CaseInsensitivePath makes the path portion of patterns match
+	// regardless of case: patterns are lowercased on registration and
+	// request paths are lowercased for matching. Only ASCII letters
+	// are folded. Matching happens on the decoded r.URL.Path, so a
+	// percent-encoded letter such as "%53" folds like the letter it
+	// encodes. Handlers still see the original request path.
+	// CaseInsensitivePath must be set before registering handlers.
+	CaseInsensitivePath bool
+
+	// SubtreeOnly404 makes the requests that only match a subtree
+	// pattern registered with another method, such as a POST to "/a/b"
+	// when "/a/" is registered for GET, be answered with 404 rather
+	// than 405: only exact matches with other methods trigger a 405.
+	SubtreeOnly404 bool
+
+	// RouteAsterisk makes ServeHTTP route the asterisk-form requests,
+	// such as "OPTIONS *", like any other request, with r.URL.Path set
+	// to "*", which no pattern matches: they are answered with 404
+	// unless matched with HandleMatch. By default, they are answered
+	// with 400 "Bad Request" like http.ServeMux does.
+	RouteAsterisk bool
+
+	// NotFoundFallback, if set, replaces NotFoundHandler for the
+	// requests that match no handler. Handlers registered with
+	// HandleNotFound and SPAFallback take precedence.
+	NotFoundFallback http.Handler
+
+	// PublicMethods, if not empty, restricts the methods whose handlers
+	// can trigger a 405: a request matching only handlers registered
+	// with methods outside PublicMethods gets a 404, so that internal
+	// methods are not disclosed.
+	PublicMethods []string
+
+	// KnownHosts, if not empty, lists the hosts that mux serves. A
+	// request for another host, such as one sent over a coalesced
+	// HTTP/2 connection, gets a 421 "Misdirected Request", unless it
+	// matches a pattern that does not specify a host. Hosts are
+	// compared case-insensitively, ignoring the port.
+	KnownHosts []string
+
+	// VerboseAllow makes the 405 "Method Not Allowed" responses carry,
+	// besides the Allow header, an X-Allowed-Patterns header listing
+	// each allowed method with the pattern that matched, such as
+	// "GET /dir/, POST /dir". Like Allow, it only depends on the
+	// request URL.
+	VerboseAllow bool
+
+	// MethodCheckBeforeRedirect makes the mux answer 405 "Method Not
+	// Allowed", rather than redirecting to the canonical path, the
+	// requests that a pattern registered for their method would only
+	// redirect, when a pattern registered for another method matches
+	// them without redirecting. For example, with "POST /dir/" and
+	// "GET /dir" registered, "POST /dir" is answered with 405 instead
+	// of being redirected to "/dir/".
+	MethodCheckBeforeRedirect bool
+
+	// NoRedirect makes the mux never redirect to the canonical path:
+	// the requests that would be redirected, either to add a trailing
+	// slash as in "/dir" to "/dir/" or to clean the path as in
+	// "/dir/./file" to "/dir/file", are answered as if no pattern
+	// matched them, with a 404 or a 405. CONNECT requests, whose path
+	// is not cleaned, are still matched unchanged. The paths to clean
+	// are rejected before matching, so that no redirect is built for
+	// them; http.ServeMux still builds the trailing-slash redirects,
+	// which are discarded.
+	NoRedirect bool
+
+	// ServerHeader, if set, is the value of the Server header that
+	// ServeHTTP sets on every response before dispatching, including
+	// the built-in 400, 404 and 405 responses. Handlers can override
+	// it.
+	ServerHeader string
+
+	// ClientIPHeader, if set, is the request header that carries the
+	// client IP address for HandleCIDR, such as "X-Forwarded-For",
+	// rather than r.RemoteAddr. Its last comma-separated entry is
+	// used, which is the one added by the nearest proxy. It must only
+	// be set behind a proxy that sets or appends to the header, as
+	// clients can forge it.
+	ClientIPHeader string
+
+	// RequireFetchMetadata makes the handlers registered with
+	// HandleSameSite reject the requests without a Sec-Fetch-Site
+	// header, rather than serving them.
+	RequireFetchMetadata bool
+
+	// Canonicalize, if set, replaces the path canonicalization of
+	// http.ServeMux, which eliminates the "." and ".." elements and
+	// the repeated slashes: the requests whose path it changes are
+	// redirected to the returned path. A function that returns the path
+	// unchanged disables the canonicalization, so that "/dir/./file" is
+	// served by "/dir/" as is. The redirect from "/dir" to "/dir/" is
+	// not affected. CONNECT requests are never canonicalized.
+	Canonicalize func(path string) string
+
+	// StrictAbsoluteForm makes ServeHTTP answer with 400 "Bad Request"
+	// the requests in absolute form, such as "GET http://a.example/x",
+	// whose URL host differs from r.Host, rather than routing them by
+	// r.Host. Such requests are a common ingredient of request
+	// smuggling through proxies that disagree on the target host.
+	//
+	// The http.Server sets r.Host to the URL host of the requests in
+	// absolute form, discarding their Host header, so the requests it
+	// parses never differ: StrictAbsoluteForm only applies to the
+	// requests built or rewritten outside of net/http, such as by a
+	// front-end that forwards them to mux in-process.
+	StrictAbsoluteForm bool
+
+	// Slog, if set, receives a structured event for each request
+	// dispatched by ServeHTTP, with the "method", "host" and "path"
+	// attributes: "matched" and "redirected", with the "pattern"
+	// attribute, and "not found" at debug level; "method not allowed"
+	// and "HTTP version not supported" at info level; "bad request",
+	// with the "reason" attribute, and "misdirected request" at warn
+	// level.
+	Slog *slog.Logger
+
+	// Fast404 makes the mux skip the lookup of the other methods for
+	// the requests that no handler registered for their method
+	// matches, answering them with 404 right away. Requests with the
+	// wrong method are then answered with 404 rather than 405, which
+	// trades accurate answers for throughput, for example under a
+	// scan of random paths.
+	Fast404 bool
+
+	// StrictPatterns makes the registration functions reject, by
+	// panicking, the patterns that have no path, such as "items",
+	// which http.ServeMux accepts as a host that no request path can
+	// match. Patterns such as "/items" and "example.com/items" are
+	// valid. HandleBatch returns an error instead.
+	StrictPatterns bool
+
+	// AutoHead makes the HEAD requests that no HEAD handler matches be
+	// served by the matching GET handler, if any. The body written by
+	// the GET handler is discarded, while its headers are kept; if it
+	// sets no Content-Length, it is set to the size of the discarded
+	// body, unless the response is flushed first. HEAD is then listed
+	// in the Allow header along with GET.
+	AutoHead bool
+
+	// CORS, if set, makes the mux answer the CORS preflight requests
+	// from the allowed origins, that is the OPTIONS requests with an
+	// Access-Control-Request-Method header that no OPTIONS handler
+	// matches, with 204 "No Content" and the Access-Control-Allow-*
+	// headers, listing the methods with a handler matching the path.
+	// The other requests from the allowed origins that match a pattern
+	// get the Access-Control-Allow-Origin header.
+	CORS *CORSConfig
+
+	// Now, if set, replaces time.Now as the clock of HandleWindow.
+	Now func() time.Time
+
+	// WindowClosedHandler, if set, replaces NotFoundHandler for the
+	// requests matching a handler registered with HandleWindow outside
+	// of its time window, such as ForbiddenHandler.
+	WindowClosedHandler http.Handler
+
+	// RequireHTTP11 makes ServeHTTP answer the HTTP/1.0 requests with
+	// 505 "HTTP Version Not Supported" before routing them, for servers
+	// that rely on HTTP/1.1 features such as chunked encoding and
+	// persistent connections. See HandleHTTP11 for a single route.
+	RequireHTTP11 bool
+
+	// PreFilter, if set, is called by ServeHTTP with each request
+	// before routing it, after setting the Server header. If it
+	// returns false, it must have answered the request, and the
+	// request is not routed. It is meant for coarse rejections, such
+	// as of banned user agents.
+	PreFilter func(w http.ResponseWriter, r *http.Request) bool
+
+	// NoRedirectForJSON makes the mux serve the requests whose Accept
+	// header prefers JSON, as API clients do, with the handler of the
+	// canonical path rather than redirecting them to it, such as
+	// "/dir" to "/dir/"; the handler sees the canonical path. Other
+	// requests are still redirected.
+	NoRedirectForJSON bool
+
+	// ErrorHandler renders the errors returned by the handlers
+	// registered with HandleErr. If nil, errors are answered with 500
+	// "Internal Server Error".
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+	// OnRegister, if set, is called on every registration with the
+	// method and the pattern, before any lock is taken. It returns the
+	// method and pattern to register instead, or an error that makes
+	// the registration panic. HandleBatch returns the error instead.
+	OnRegister func(method, pattern string) (string, string, error)
+
+	// SetRequestPattern makes ServeHTTP set r.Pattern to the matched
+	// pattern before calling the handler, so that the handler and its
+	// middleware can read it like with http.ServeMux.
+	SetRequestPattern bool
+
+	// OnServed, if set, is called by ServeHTTP after each routed
+	// request has been served, with the matched pattern (empty if no
+	// pattern matched) and the status code of the response. The
+	// patterns have a bounded cardinality, which makes them suitable
+	// as metric labels.
+	OnServed func(r *http.Request, pattern string, status int)
+
+	// SlowThreshold, if positive, is the duration beyond which serving
+	// a request is reported to OnSlow.
+	SlowThreshold time.Duration
+
+	// OnSlow, if set, is called by ServeHTTP after each routed request
+	// whose handler took longer than SlowThreshold to return, with the
+	// matched pattern (empty if no pattern matched) and the duration.
+	OnSlow func(r *http.Request, pattern string, d time.Duration)
+
+	// Interceptor, if set, is called by ServeHTTP after routing each
+	// request, with the matched handler and pattern. The returned
+	// handler serves the request in place of the matched one; if it is
+	// nil, the matched handler is used.
+	Interceptor func(r *http.Request, matched http.Handler, pattern string) http.Handler
+
+	// ValidateTrailingSlash makes Validate also report the paths
+	// registered both with and without a trailing slash; see
+	// TrailingSlashPair.
+	ValidateTrailingSlash bool
+
+	mu      sync.RWMutex
+	m       map[string]*http.ServeMux
+	methods []string // keys of m, in registration order
+	routes  map[string]map[string]*route
+	ports   map[string]map[string]*http.ServeMux
+	params  map[string][]paramRoute
+	aliases map[string]string // see AliasMethod
+
+	methodPrefixes []methodPrefix // longest prefix first
+
+	// index holds the patterns of all methods; see crossMethodIndex.
+	// It is reset on registration, and built lazily under the read
+	// lock. unindexed is set once MethodMux has exposed a sub-mux.
+	index     atomic.Pointer[patternIndex]
+	unindexed atomic.Bool
+
+	notFound []prefixHandler // longest prefix first
+	spa      http.Handler
+
+	matchFirst, matchLast []customMatcher
+
+	stats counters
 }
 
 // New allocates and returns a new ServeMux.
@@ -81,18 +400,159 @@ func New() *ServeMux {
 // If a handler already exists for the combination of method and pattern, Handle panics.
 // The documentation for http.ServeMux explains how patterns are matched.
 func (mux *ServeMux) Handle(method, pattern string, handler http.Handler) {
+	method, pattern = mux.onRegister(method, pattern)
+
 	mux.mu.Lock()
 	defer mux.mu.Unlock()
 
+	mux.handle(method, pattern, handler)
+}
+
+// onRegister applies OnRegister, if set, panicking on error.
+func (mux *ServeMux) onRegister(method, pattern string) (string, string) {
+	if mux.OnRegister == nil {
+		return method, pattern
+	}
+	m, p, err := mux.OnRegister(method, pattern)
+	if err != nil {
+		panic("methodmux: " + method + " " + pattern + ": " + err.Error())
+	}
+	return m, p
+}
+
+// handle registers the handler for the given method and pattern. The
+// caller must hold the write lock.
+func (mux *ServeMux) handle(method, pattern string, handler http.Handler) {
+	if handler == nil {
+		panic("methodmux: nil handler")
+	}
+
+	rt := mux.routeFor(method, pattern)
+	if rt.handler != nil {
+		panic("methodmux: multiple registrations for " + method + " " + pattern)
+	}
+	rt.handler = handler
+}
+
+// routeFor returns the route for the given method and pattern,
+// registering it to the underlying http.ServeMux if it does not exist.
+// The caller must hold the write lock.
+func (mux *ServeMux) routeFor(method, pattern string) *route {
+	if err := mux.checkPattern(pattern); err != nil {
+		panic(err.Error())
+	}
+	pattern = mux.foldPattern(pattern)
+
+	if rt, exists := mux.routes[method][pattern]; exists {
+		return rt
+	}
+
 	if mux.m == nil {
 		mux.m = make(map[string]*http.ServeMux)
 	}
 
 	if _, exists := mux.m[method]; !exists {
 		mux.m[method] = http.NewServeMux()
+		mux.methods = append(mux.methods, method)
+	}
+
+	rt := new(route)
+	mux.m[method].Handle(pattern, rt)
+	mux.index.Store(nil)
+
+	if mux.routes == nil {
+		mux.routes = make(map[string]map[string]*route)
 	}
 
-	mux.m[method].Handle(pattern, handler)
+	if _, exists := mux.routes[method]; !exists {
+		mux.routes[method] = make(map[string]*route)
+	}
+
+	mux.routes[method][pattern] = rt
+	return rt
+}
+
+// HandlePort registers the handler for the given method and pattern,
+// only for requests addressed to the given port. The port is read from
+// r.Host; if r.Host has no port, the default port for the scheme (80,
+// or 443 for TLS requests) is assumed.
+//
+// Port-scoped handlers take precedence over the handlers registered
+// with Handle, which remain the fallback for requests that no
+// port-scoped handler matches. Like those, they make the requests to
+// their port with another method be answered with 405.
+func (mux *ServeMux) HandlePort(port, method, pattern string, handler http.Handler) {
+	method, pattern = mux.onRegister(method, pattern)
+
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	if handler == nil {
+		panic("methodmux: nil handler")
+	}
+
+	if err := mux.checkPattern(pattern); err != nil {
+		panic(err.Error())
+	}
+
+	port = strings.TrimPrefix(port, ":")
+	pattern = mux.foldPattern(pattern)
+
+	if mux.ports == nil {
+		mux.ports = make(map[string]map[string]*http.ServeMux)
+	}
+
+	if _, exists := mux.ports[port]; !exists {
+		mux.ports[port] = make(map[string]*http.ServeMux)
+	}
+
+	if _, exists := mux.ports[port][method]; !exists {
+		mux.ports[port][method] = http.NewServeMux()
+	}
+
+	mux.ports[port][method].Handle(pattern, &route{handler: handler})
+}
+
+// Reset removes all the handlers registered to mux, including those
+// registered with HandlePort, HandleParam, HandleMethodPrefix,
+// HandleNotFound, SPAFallback and HandleMatch, as well as the aliases
+// set with AliasMethod, so that it can be populated again. The exported
+// configuration fields are left untouched.
+func (mux *ServeMux) Reset() {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	mux.m = nil
+	mux.methods = nil
+	mux.routes = nil
+	mux.ports = nil
+	mux.params = nil
+	mux.aliases = nil
+	mux.methodPrefixes = nil
+	mux.notFound = nil
+	mux.spa = nil
+	mux.matchFirst = nil
+	mux.matchLast = nil
+	mux.index.Store(nil)
+}
+
+// MethodMux returns the underlying http.ServeMux that holds the
+// handlers registered with Handle for the given method, if any.
+//
+// The returned ServeMux is shared with mux. Handlers registered to it
+// directly are served by mux, but are not reported by Routes and
+// ignore the options that apply at registration, such as
+// CaseInsensitivePath. Serving requests through it directly bypasses
+// every option of mux, and is not synchronized with Swap.
+func (mux *ServeMux) MethodMux(method string) (*http.ServeMux, bool) {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+
+	m, exists := mux.m[method]
+	if exists {
+		mux.unindexed.Store(true)
+	}
+	return m, exists
 }
 
 // HandleFunc registers the handler function for the given method and pattern.
@@ -109,34 +569,575 @@ func (mux *ServeMux) HandleFunc(method, pattern string, handler func(http.Respon
 //
 // The path and host are used unchanged for CONNECT requests.
 //
+// If NormalizeMethod is set, whitespace surrounding r.Method is
+// ignored. Method aliases registered with AliasMethod are then
+// resolved.
+//
+// Handlers registered with HandlePort for the port of the request are
+// consulted before the others. Custom matchers registered with
+// HandleMatch are consulted first or last, depending on their priority;
+// the returned pattern is empty when one of them matches. Templates
+// registered with HandleParam are consulted when no pattern matches.
+//
+// Redirects use RedirectCode if set, or 308 if PreserveMethodOnRedirect
+// is set, and carry over the query string.
+//
 // Handler also returns the registered pattern that matches the
 // request or, in the case of internally-generated redirects,
 // the pattern that will match after following the redirect.
 //
+// A redirect to the canonical path for the request method takes
+// precedence over the patterns registered for other methods, unless
+// MethodCheckBeforeRedirect is set.
+//
 // If there is no registered handler that applies to the request,
 // Handler checks the other methods on the same pattern.
 // If the same pattern matches with a handle that responds to another
 // HTTP method, a "Method Not Allowed" handler is returned with an
-// empty pattern. If no HTTP method would trigger a registered
-// handler, "Not Found" handler is returned with an empty pattern.
+// empty pattern. It sets the Allow header to the sorted list of the
+// methods that would be served. If no HTTP method would trigger a registered
+// handler, "Not Found" handler is returned with an empty pattern; see
+// HandleNotFound.
 func (mux *ServeMux) Handler(r *http.Request) (h http.Handler, pattern string) {
+	m := mux.resolve(r)
+	return m.handler, m.pattern
+}
+
+// RawHandler returns the handler that serves the requests with the
+// given method, host and path, like Handler. If the path is not in its
+// canonical form, isRedirect is true and, rather than the redirect
+// handler, RawHandler returns the handler that serves the canonical
+// path, which the client reaches by following the redirect.
+func (mux *ServeMux) RawHandler(method, host, path string) (h http.Handler, pattern string, isRedirect bool) {
+	r := &http.Request{
+		Method: method,
+		Host:   host,
+		URL:    &url.URL{Path: path},
+		Header: make(http.Header),
+	}
+
+	m := mux.resolve(r)
+	// A path can need cleaning, then a trailing slash, then
+	// canonicalizing again after a case-insensitive match.
+	for i := 0; i < 3 && m.kind == MatchRedirect; i++ {
+		isRedirect = true
+		m = mux.resolve(withPath(r, m.target))
+	}
+	return m.handler, m.pattern, isRedirect
+}
+
+// Match resolves a request with the given method, host and path,
+// without serving it, and returns the matched pattern along with the
+// kind of the match. Unlike RawHandler, it does not follow redirects:
+// for MatchRedirect, the pattern is the one matching the canonical
+// path. The pattern is empty for MatchNotFound and
+// MatchMethodNotAllowed.
+func (mux *ServeMux) Match(method, host, path string) (pattern string, kind MatchKind) {
+	m := mux.resolve(&http.Request{
+		Method: method,
+		Host:   host,
+		URL:    &url.URL{Path: path},
+		Header: make(http.Header),
+	})
+	return m.pattern, m.kind
+}
+
+// MethodsForPath returns the sorted methods with a pattern matching
+// the given host and path: those that a request with another method
+// would be told are allowed, in the Allow header of a 405 response.
+// Like the Allow header, it honours PublicMethods and SubtreeOnly404.
+func (mux *ServeMux) MethodsForPath(host, path string) []string {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+
+	if mux.CaseInsensitivePath {
+		path = lowerASCII(path)
+	}
+	r := &http.Request{
+		Host:   host,
+		URL:    &url.URL{Path: path},
+		Header: make(http.Header),
+	}
+
+	var methods []string
+	for method := range mux.allowedMethods("", r, false) {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// match is the outcome of resolving a request.
+type match struct {
+	kind    MatchKind
+	handler http.Handler
+	pattern string
+	route   *route // the matching route, for MatchRoute
+	target  string // the canonical path, for MatchRedirect
+}
+
+// MatchKind tells how a request is resolved; see Match.
+type MatchKind int
+
+const (
+	// MatchRoute is a handler registered for the method of the
+	// request, with Handle or one of its variants.
+	MatchRoute MatchKind = iota
+
+	// MatchRedirect is a redirect to the canonical path, such as
+	// "/dir" to "/dir/".
+	MatchRedirect
+
+	// MatchCustom is a handler registered by other means, such as
+	// HandleMatch and HandleParam, or one of the built-in answers that
+	// are neither a 404 nor a 405, such as a CORS preflight response.
+	MatchCustom
+
+	// MatchNotFound is the answer to a request that no pattern
+	// matches, with any method.
+	MatchNotFound
+
+	// MatchMethodNotAllowed is the 405 answer to a request that only
+	// patterns registered for other methods match.
+	MatchMethodNotAllowed
+)
+
+// resolve finds the handler to use for the given request. See Handler.
+func (mux *ServeMux) resolve(r *http.Request) match {
+	m := mux.resolveOnce(r)
+	if m.kind != MatchRedirect || !mux.NoRedirectForJSON {
+		return m
+	}
+
+	var path string
+	if prefersJSON(r) {
+		// As in RawHandler, follow the redirects.
+		for i := 0; i < 3 && m.kind == MatchRedirect; i++ {
+			r = withPath(r, m.target)
+			m = mux.resolveOnce(r)
+		}
+		if m.kind == MatchRedirect {
+			m = match{kind: MatchNotFound, handler: NotFoundHandler}
+		}
+		if m.kind == MatchRoute || m.kind == MatchCustom {
+			path = r.URL.Path
+		}
+	}
+
+	// Whether the request is redirected depends on its Accept header.
+	h := m.handler
+	m.handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addVary(w.Header(), "Accept")
+		if path != "" {
+			r = withPath(r, path)
+		}
+		h.ServeHTTP(w, r)
+	})
+	return m
+}
+
+// prefersJSON reports whether the Accept header of r prefers JSON, as
+// "application/json" or a media type with the "+json" suffix.
+func prefersJSON(r *http.Request) bool {
+	accepted := accepted(r.Header.Get("Accept"))
+	return len(accepted) > 0 && (accepted[0] == "application/json" || strings.HasSuffix(accepted[0], "+json"))
+}
+
+// resolveOnce finds the handler to use for the given request, without
+// following the redirects. See resolve.
+func (mux *ServeMux) resolveOnce(r *http.Request) match {
 	mux.mu.RLock()
 	defer mux.mu.RUnlock()
 
-	if _, exists := mux.m[r.Method]; exists {
-		h, pattern = mux.m[r.Method].Handler(r)
+	method := r.Method
+	if mux.NormalizeMethod {
+		method = strings.TrimSpace(method)
+	}
+	if canonical, ok := mux.aliases[method]; ok {
+		method = canonical
+	}
+
+	r = withoutFragment(r)
+	if mux.CaseInsensitivePath {
+		r = withPath(r, lowerASCII(r.URL.Path))
+	}
+
+	if h := firstMatch(mux.matchFirst, r); h != nil {
+		return match{kind: MatchCustom, handler: h}
+	}
+
+	if m, exists := mux.ports[requestPort(r)][method]; exists {
+		if match := mux.lookup(m, r); match.pattern != "" {
+			return match
+		}
 	}
 
+	if m, exists := mux.m[method]; exists {
+		if match := mux.lookup(m, r); match.pattern != "" {
+			if match.kind == MatchRedirect && mux.MethodCheckBeforeRedirect {
+				if allowed := mux.allowedMethods(method, r, true); len(allowed) > 0 {
+					return mux.methodNotAllowed(allowed)
+				}
+			}
+			return match
+		}
+	}
+
+	if match := mux.matchParam(method, r); match.pattern != "" {
+		return match
+	}
+
+	if match := mux.matchMethodPrefix(method, r); match.pattern != "" {
+		return match
+	}
+
+	if mux.AutoHead && method == http.MethodHead {
+		if match := mux.headFromGet(r); match.pattern != "" {
+			return match
+		}
+	}
+
+	if mux.CORS != nil && method == http.MethodOptions && mux.CORS.isPreflight(r) {
+		if match := mux.preflight(mux.allowedMethods(method, r, true)); match.pattern != "" {
+			return match
+		}
+	}
+
+	if mux.Fast404 {
+		return mux.unmatched(method, r)
+	}
+
+	// With a single method, the lookup above was the whole scan.
+	if len(mux.methods) == 1 && mux.methods[0] == method && len(mux.params) == 0 && len(mux.ports) == 0 {
+		return mux.unmatched(method, r)
+	}
+
+	if allowed := mux.allowedMethods(method, r, false); len(allowed) > 0 {
+		return mux.methodNotAllowed(allowed)
+	}
+
+	return mux.unmatched(method, r)
+}
+
+// allowedMethods returns the methods other than method that have a
+// pattern matching r, mapped to the matching patterns. If exact is
+// set, the patterns that would only redirect r are not considered. The
+// caller must hold the read lock.
+func (mux *ServeMux) allowedMethods(method string, r *http.Request, exact bool) map[string][]string {
+	var allowed map[string][]string
+	add := func(method, pattern string) {
+		if allowed == nil {
+			allowed = make(map[string][]string)
+		}
+		allowed[method] = append(allowed[method], pattern)
+	}
+
+	scan := func(other string, m *http.ServeMux) {
+		if other == method || !mux.isPublic(other) {
+			return
+		}
+		crossMethod := mux.lookup(m, r)
+		if crossMethod.pattern == "" || (exact && crossMethod.kind == MatchRedirect) {
+			return
+		}
+		if !mux.SubtreeOnly404 || !isSubtreeMatch(crossMethod, r) {
+			add(other, crossMethod.pattern)
+		}
+	}
+
+	methods := mux.methods
+	if index := mux.crossMethodIndex(); index != nil {
+		if _, pattern := index.Handler(r); pattern == "" {
+			methods = nil
+		}
+	}
+	for _, other := range methods {
+		scan(other, mux.m[other])
+	}
+
+	// The port-scoped patterns are not in the index.
+	for other, m := range mux.ports[requestPort(r)] {
+		scan(other, m)
+	}
+
+	for other := range mux.params {
+		if other == method || !mux.isPublic(other) {
+			continue
+		}
+		if crossMethod := mux.matchParam(other, r); crossMethod.pattern != "" {
+			add(other, crossMethod.pattern)
+		}
+	}
+
+	// With AutoHead, the GET handlers serve HEAD too.
+	if _, exists := allowed[http.MethodHead]; mux.AutoHead && !exists && method != http.MethodHead && mux.isPublic(http.MethodHead) {
+		for _, pattern := range allowed[http.MethodGet] {
+			add(http.MethodHead, pattern)
+		}
+	}
+
+	return allowed
+}
+
+// methodNotAllowed returns the match for a request that patterns
+// registered for other methods match. allowed maps each of those
+// methods to the matching patterns.
+func (mux *ServeMux) methodNotAllowed(allowed map[string][]string) match {
+	methods := make([]string, 0, len(allowed))
+	for method := range allowed {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	allow := strings.Join(methods, ", ")
+
+	var patterns string
+	if mux.VerboseAllow {
+		var matched []string
+		for _, method := range methods {
+			for _, pattern := range allowed[method] {
+				matched = append(matched, method+" "+pattern)
+			}
+		}
+		patterns = strings.Join(matched, ", ")
+	}
+
+	return match{kind: MatchMethodNotAllowed, handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		if patterns != "" {
+			w.Header().Set("X-Allowed-Patterns", patterns)
+		}
+		MethodNotAllowedHandler.ServeHTTP(w, r)
+	})}
+}
+
+// headFromGet returns the match for the HEAD request r among the GET
+// handlers, serving them without a body. If none matches, the returned
+// pattern is empty. The caller must hold the read lock.
+func (mux *ServeMux) headFromGet(r *http.Request) match {
+	var m match
+	if get, exists := mux.m[http.MethodGet]; exists {
+		m = mux.lookup(get, r)
+	}
+	if m.pattern == "" {
+		m = mux.matchParam(http.MethodGet, r)
+	}
+	if m.pattern == "" || m.kind == MatchRedirect {
+		return m
+	}
+
+	h := m.handler
+	m.handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hw := &headWriter{ResponseWriter: w}
+		h.ServeHTTP(hw, r)
+		hw.finish()
+	})
+	return m
+}
+
+// unmatched returns the match for a request that no registered pattern
+// matches, with any method. The caller must hold the read lock.
+func (mux *ServeMux) unmatched(method string, r *http.Request) match {
+	if h := firstMatch(mux.matchLast, r); h != nil {
+		return match{kind: MatchCustom, handler: h}
+	}
+
+	return match{kind: MatchNotFound, handler: mux.notFoundHandler(method, r)}
+}
+
+// checkPattern returns an error if StrictPatterns is set and pattern
+// has no path: neither a leading slash nor a host followed by a path.
+func (mux *ServeMux) checkPattern(pattern string) error {
+	if mux.StrictPatterns && pattern != "" && !strings.Contains(pattern, "/") {
+		return errors.New("methodmux: invalid pattern " + strconv.Quote(pattern) + ": the path must start with a slash")
+	}
+	return nil
+}
+
+// foldPattern lowercases the path portion of pattern if
+// CaseInsensitivePath is set.
+func (mux *ServeMux) foldPattern(pattern string) string {
+	if !mux.CaseInsensitivePath {
+		return pattern
+	}
+	i := strings.Index(pattern, "/")
+	if i < 0 {
+		return pattern
+	}
+	return pattern[:i] + lowerASCII(pattern[i:])
+}
+
+// lookup resolves r against m, returning the registered handler rather
+// than its route wrapper. If nothing matches, the returned pattern is
+// empty.
+func (mux *ServeMux) lookup(m *http.ServeMux, r *http.Request) match {
+	// Cleaning "*" would turn it into "/*", which patterns such as "/"
+	// match.
+	if r.URL.Path == "*" {
+		return mux.lookupPath(m, uncleanedRequest(r))
+	}
+	if mux.Canonicalize == nil || r.Method == http.MethodConnect {
+		return mux.lookupPath(m, r)
+	}
+
+	canonical := mux.Canonicalize(r.URL.Path)
+	if canonical == r.URL.Path {
+		return mux.lookupPath(m, uncleanedRequest(r))
+	}
+	if mux.NoRedirect {
+		return match{kind: MatchNotFound, handler: NotFoundHandler}
+	}
+	target := mux.lookupPath(m, uncleanedRequest(withPath(r, canonical)))
+	if target.pattern == "" {
+		return target
+	}
+	return match{kind: MatchRedirect, handler: redirectHandler(r, canonical, mux.redirectCode()), pattern: target.pattern, target: canonical}
+}
+
+// lookupPath is like lookup, with the path canonicalization of
+// http.ServeMux.
+func (mux *ServeMux) lookupPath(m *http.ServeMux, r *http.Request) match {
+	// Rejecting the paths to clean up front spares http.ServeMux
+	// building the redirects to their canonical form.
+	if mux.NoRedirect && r.Method != http.MethodConnect {
+		if cleanPath(r.URL.Path) != r.URL.Path {
+			return match{kind: MatchNotFound, handler: NotFoundHandler}
+		}
+		r = uncleanedRequest(r)
+	}
+
+	h, pattern := m.Handler(r)
+	if rt, ok := h.(*route); ok {
+		if rt.disabled.Load() {
+			return mux.fallThrough(m, r, rt)
+		}
+		if h := rt.handlerFor(r); h != nil {
+			if rt.draining.Load() {
+				h = ServiceUnavailableHandler
+			}
+			return match{kind: MatchRoute, handler: h, pattern: pattern, route: rt}
+		}
+		return mux.fallThrough(m, r, rt)
+	}
 	if pattern == "" {
-		for _, mux := range mux.m {
-			if _, crossMethodPattern := mux.Handler(r); crossMethodPattern != "" {
-				return MethodNotAllowedHandler, ""
+		return match{kind: MatchNotFound, handler: h}
+	}
+	if !isRedirect(h) {
+		// Registered to the http.ServeMux directly; see MethodMux.
+		return match{kind: MatchCustom, handler: h, pattern: pattern}
+	}
+	if mux.NoRedirect {
+		return match{kind: MatchNotFound, handler: NotFoundHandler}
+	}
+
+	// For trailing-slash redirects, http.ServeMux reports the target
+	// path rather than the host-qualified pattern that may serve it.
+	target := redirectTarget(r, pattern)
+	targetHandler, targetPattern := m.Handler(withPath(r, target))
+	if rt, ok := targetHandler.(*route); ok && rt.disabled.Load() {
+		return mux.fallThrough(m, r, rt)
+	}
+	if targetPattern != "" {
+		pattern = targetPattern
+	}
+
+	if code := mux.redirectCode(); code != http.StatusMovedPermanently {
+		h = redirectHandler(r, target, code)
+	}
+	return match{kind: MatchRedirect, handler: h, pattern: pattern, target: target}
+}
+
+// fallThrough matches r against the enabled routes registered to m
+// other than rt, which matched r but does not serve it, as if they were
+// deregistered, skipping in turn the routes whose conditional handlers
+// do not match r either. The patterns are matched by a temporary copy
+// of m, as http.ServeMux cannot skip a pattern. The caller must hold
+// the read lock.
+func (mux *ServeMux) fallThrough(m *http.ServeMux, r *http.Request, rt *route) match {
+	var routes map[string]*route
+	for _, method := range mux.methods {
+		if mux.m[method] == m {
+			routes = mux.routes[method]
+		}
+	}
+
+	skipped := map[*route]bool{rt: true}
+	for {
+		fallback := http.NewServeMux()
+		for pattern, other := range routes {
+			if !skipped[other] && !other.disabled.Load() {
+				fallback.Handle(pattern, other)
 			}
 		}
-		return NotFoundHandler, ""
+		h, _ := fallback.Handler(r)
+		if next, ok := h.(*route); ok && next.handlerFor(r) == nil {
+			skipped[next] = true
+			continue
+		}
+		return mux.lookupPath(fallback, r)
+	}
+}
+
+// isKnownHost reports whether host is served by mux; see KnownHosts.
+func (mux *ServeMux) isKnownHost(host string) bool {
+	if len(mux.KnownHosts) == 0 {
+		return true
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	for _, known := range mux.KnownHosts {
+		if strings.EqualFold(known, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPublic reports whether method can trigger a 405; see PublicMethods.
+func (mux *ServeMux) isPublic(method string) bool {
+	if len(mux.PublicMethods) == 0 {
+		return true
+	}
+	for _, m := range mux.PublicMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// isSubtreeMatch reports whether m matched r through a subtree
+// pattern, rather than exactly.
+func isSubtreeMatch(m match, r *http.Request) bool {
+	if m.kind != MatchRoute {
+		return false
+	}
+	p := patternPath(m.pattern)
+	return strings.HasSuffix(p, "/") && p != r.URL.Path
+}
+
+// redirectCode returns the status code of the redirects to the
+// canonical path.
+func (mux *ServeMux) redirectCode() int {
+	switch {
+	case mux.RedirectCode != 0:
+		return mux.RedirectCode
+	case mux.PreserveMethodOnRedirect:
+		return http.StatusPermanentRedirect
+	default:
+		return http.StatusMovedPermanently
 	}
+}
 
-	return h, pattern
+// requestPort returns the port the request has been addressed to.
+func requestPort(r *http.Request) string {
+	if _, port, err := net.SplitHostPort(r.Host); err == nil && port != "" {
+		return port
+	}
+	if r.TLS != nil {
+		return "443"
+	}
+	return "80"
 }
 
 // ServeHTTP dispatches the request to the handler registered
@@ -144,15 +1145,108 @@ func (mux *ServeMux) Handler(r *http.Request) (h http.Handler, pattern string) {
 // closely matches the request URL.
 // If no registered matcher is found, a 405 is returned if there
 // is a match with another HTTP method. Otherwise, a 404 is returned.
+// If DisableTrace is set, TRACE requests are answered with a 405.
 func (mux *ServeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if r.RequestURI == "*" {
+	mux.stats.requests.Add(1)
+
+	if mux.ServerHeader != "" {
+		w.Header().Set("Server", mux.ServerHeader)
+	}
+
+	if mux.PreFilter != nil && !mux.PreFilter(w, r) {
+		return
+	}
+
+	if !mux.RouteAsterisk && r.RequestURI == "*" {
 		if r.ProtoAtLeast(1, 1) {
 			w.Header().Set("Connection", "close")
 		}
+		mux.log(r, slog.LevelWarn, "bad request", slog.String("reason", "asterisk-form request"))
+		BadRequestHandler.ServeHTTP(w, r)
+		return
+	}
+
+	if mux.StrictAbsoluteForm && r.URL.Host != "" && !strings.EqualFold(r.URL.Host, r.Host) {
+		mux.log(r, slog.LevelWarn, "bad request", slog.String("reason", "absolute-form host mismatch"))
 		BadRequestHandler.ServeHTTP(w, r)
 		return
 	}
 
-	h, _ := mux.Handler(r)
-	h.ServeHTTP(w, r)
+	if mux.DisableTrace && r.Method == http.MethodTrace {
+		mux.log(r, slog.LevelInfo, "method not allowed", slog.String("reason", "TRACE disabled"))
+		MethodNotAllowedHandler.ServeHTTP(w, r)
+		return
+	}
+
+	if mux.RequireHTTP11 && !r.ProtoAtLeast(1, 1) {
+		mux.log(r, slog.LevelInfo, "HTTP version not supported")
+		HTTPVersionNotSupportedHandler.ServeHTTP(w, r)
+		return
+	}
+
+	if mux.ContextFunc != nil {
+		if ctx := mux.ContextFunc(r); ctx != nil {
+			r = r.WithContext(ctx)
+		}
+	}
+
+	m := mux.resolve(r)
+	if !mux.isKnownHost(r.Host) && !strings.HasPrefix(m.pattern, "/") {
+		m = match{kind: MatchCustom, handler: MisdirectedRequestHandler}
+		mux.log(r, slog.LevelWarn, "misdirected request")
+	} else {
+		mux.logMatch(r, m)
+	}
+	mux.count(m)
+
+	if mux.CORS != nil && m.kind != MatchNotFound {
+		mux.CORS.setOrigin(w, r)
+	}
+
+	if mux.Interceptor != nil {
+		if h := mux.Interceptor(r, m.handler, m.pattern); h != nil {
+			m.handler = h
+		}
+	}
+
+	if mux.SetRequestPattern && m.kind == MatchRoute {
+		r.Pattern = m.pattern
+	}
+
+	if mux.SlowThreshold > 0 && mux.OnSlow != nil {
+		start := time.Now()
+		defer func() {
+			if d := time.Since(start); d > mux.SlowThreshold {
+				mux.OnSlow(r, m.pattern, d)
+			}
+		}()
+	}
+
+	if mux.OnServed == nil {
+		m.handler.ServeHTTP(w, r)
+		return
+	}
+
+	sw := &statusWriter{ResponseWriter: w}
+	m.handler.ServeHTTP(sw.withOptional(), r)
+	mux.OnServed(r, m.pattern, sw.code())
+}
+
+// Readonly returns a Router backed by mux. The returned value routes
+// requests exactly like mux, including handlers registered on mux
+// later, but exposes no way to register new handlers.
+func (mux *ServeMux) Readonly() Router {
+	return readonlyMux{mux: mux}
+}
+
+type readonlyMux struct {
+	mux *ServeMux
+}
+
+func (ro readonlyMux) Handler(r *http.Request) (http.Handler, string) {
+	return ro.mux.Handler(r)
+}
+
+func (ro readonlyMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ro.mux.ServeHTTP(w, r)
 }
@@ -1,11 +1,19 @@
 package methodmux_test
 
 import (
+	"bufio"
+	"context"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	. "github.com/pierreprinetti/go-methodmux"
 )
@@ -168,23 +176,1468 @@ func TestServeHTTP(t *testing.T) {
 			t.Errorf("expected \"Connection: %s\" header, found %q", want, have)
 		}
 	})
+
+	t.Run("bad request on * with the zero value", func(t *testing.T) {
+		var s ServeMux
+		s.Handle("OPTIONS", "/", serve(200))
+
+		rw := httptest.NewRecorder()
+		s.ServeHTTP(rw, httptest.NewRequest("OPTIONS", "*", nil))
+		if want, have := 400, rw.Code; have != want {
+			t.Errorf("expected status code %d, found %d", want, have)
+		}
+	})
+
+	t.Run("routes * if RouteAsterisk is set", func(t *testing.T) {
+		s := New()
+		s.RouteAsterisk = true
+		s.Handle("GET", "/", serve(200))
+		s.Handle("OPTIONS", "/", serve(200))
+
+		rw := httptest.NewRecorder()
+		s.ServeHTTP(rw, httptest.NewRequest("OPTIONS", "*", nil))
+		if want, have := 404, rw.Code; have != want {
+			t.Errorf("expected status code %d, found %d", want, have)
+		}
+		if allow := rw.Header().Get("Allow"); allow != "" {
+			t.Errorf("expected no Allow header, found %q", allow)
+		}
+
+		rw = httptest.NewRecorder()
+		s.ServeHTTP(rw, httptest.NewRequest("POST", "*", nil))
+		if want, have := 404, rw.Code; have != want {
+			t.Errorf("expected status code %d, found %d", want, have)
+		}
+
+		s.HandleMatch(func(r *http.Request) bool { return r.URL.Path == "*" }, serve(204), BeforeRoutes)
+		rw = httptest.NewRecorder()
+		s.ServeHTTP(rw, httptest.NewRequest("OPTIONS", "*", nil))
+		if want, have := 204, rw.Code; have != want {
+			t.Errorf("expected status code %d, found %d", want, have)
+		}
+	})
+}
+
+func TestHandleFunc(t *testing.T) {
+	t.Run("registers a handler", func(t *testing.T) {
+		req := httptest.NewRequest("DELETE", "/some/path", nil)
+		rw := &httptest.ResponseRecorder{}
+		s := New()
+		s.HandleFunc("DELETE", "/some/path", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(512)
+		})
+		s.ServeHTTP(rw, req)
+		if want, have := 512, rw.Code; have != want {
+			t.Errorf("expected status code %d, found %d", want, have)
+		}
+	})
+}
+
+func TestNormalizeMethod(t *testing.T) {
+	testCases := [...]struct {
+		name            string
+		normalize       bool
+		expectedCode    int
+		expectedPattern string
+	}{
+		{"normalization off", false, 405, ""},
+		{"normalization on", true, 200, "/some/path"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mux := New()
+			mux.NormalizeMethod = tc.normalize
+			mux.Handle("GET", "/some/path", serve(200))
+
+			r := &http.Request{
+				Method: " GET ",
+				Host:   "example.com",
+				URL:    &url.URL{Path: "/some/path"},
+			}
+			h, pattern := mux.Handler(r)
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, r)
+			if have, want := rr.Code, tc.expectedCode; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+			if have, want := pattern, tc.expectedPattern; have != want {
+				t.Errorf("expected pattern %q, found %q", want, have)
+			}
+		})
+	}
+}
+
+var _ Router = (*ServeMux)(nil)
+
+func TestReadonly(t *testing.T) {
+	t.Run("routes like the mux", func(t *testing.T) {
+		mux := New()
+		ro := mux.Readonly()
+		mux.Handle("GET", "/some/path", serve(200))
+
+		if _, ok := ro.(*ServeMux); ok {
+			t.Errorf("expected the read-only view not to be a *ServeMux")
+		}
+
+		rw := httptest.NewRecorder()
+		ro.ServeHTTP(rw, httptest.NewRequest("GET", "/some/path", nil))
+		if want, have := 200, rw.Code; have != want {
+			t.Errorf("expected status code %d, found %d", want, have)
+		}
+
+		_, pattern := ro.Handler(httptest.NewRequest("POST", "/some/path", nil))
+		if want, have := "", pattern; have != want {
+			t.Errorf("expected pattern %q, found %q", want, have)
+		}
+	})
+}
+
+func TestDisableTrace(t *testing.T) {
+	testCases := [...]struct {
+		name         string
+		disableTrace bool
+		expectedCode int
+	}{
+		{"TRACE allowed", false, 200},
+		{"TRACE blocked", true, 405},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			mux := New()
+			mux.DisableTrace = tc.disableTrace
+			mux.Handle("TRACE", "/some/path", serve(200))
+			mux.ServeHTTP(rw, httptest.NewRequest("TRACE", "/some/path", nil))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+}
+
+func TestHandlePort(t *testing.T) {
+	testCases := [...]struct {
+		method          string
+		host            string
+		path            string
+		expectedCode    int
+		expectedPattern string
+		expectedAllow   string
+	}{
+		{"GET", "example.com:8080", "/admin", 200, "/admin", ""},
+		{"GET", "example.com:80", "/admin", 404, "", ""},
+		{"GET", "example.com", "/admin", 404, "", ""},
+		{"GET", "example.com:8080", "/public", 201, "/public", ""},
+		{"GET", "example.com:80", "/public", 201, "/public", ""},
+		{"POST", "example.com:8080", "/admin", 405, "", "GET"},
+		{"POST", "example.com:80", "/admin", 404, "", ""},
+		{"POST", "example.com:8080", "/public", 405, "", "GET"},
+	}
+
+	mux := New()
+	mux.HandlePort("8080", "GET", "/admin", serve(200))
+	mux.Handle("GET", "/public", serve(201))
+
+	for _, tc := range testCases {
+		t.Run(tc.method+" "+tc.host+tc.path, func(t *testing.T) {
+			r := &http.Request{
+				Method: tc.method,
+				Host:   tc.host,
+				URL:    &url.URL{Path: tc.path},
+			}
+			h, pattern := mux.Handler(r)
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, r)
+			if have, want := rr.Code, tc.expectedCode; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+			if have, want := pattern, tc.expectedPattern; have != want {
+				t.Errorf("expected pattern %q, found %q", want, have)
+			}
+			if have, want := rr.Header().Get("Allow"), tc.expectedAllow; have != want {
+				t.Errorf("expected Allow %q, found %q", want, have)
+			}
+		})
+	}
+}
+
+func TestRedirectCode(t *testing.T) {
+	testCases := [...]struct {
+		redirectCode     int
+		path             string
+		expectedCode     int
+		expectedLocation string
+	}{
+		{0, "/dir?a=1", 301, "/dir/?a=1"},
+		{307, "/dir?a=1", 307, "/dir/?a=1"},
+		{308, "/dir?a=1", 308, "/dir/?a=1"},
+		{308, "/dir/./file?a=1", 308, "/dir/file?a=1"},
+		{308, "/dir/", 200, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%d %s", tc.redirectCode, tc.path), func(t *testing.T) {
+			mux := New()
+			mux.RedirectCode = tc.redirectCode
+			mux.Handle("GET", "/dir/", serve(200))
+
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest("GET", tc.path, nil))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+			if want, have := tc.expectedLocation, rw.Header().Get("Location"); have != want {
+				t.Errorf("expected Location %q, found %q", want, have)
+			}
+		})
+	}
+}
+
+func TestContextFunc(t *testing.T) {
+	type key struct{}
+
+	testCases := [...]struct {
+		name          string
+		contextFunc   func(*http.Request) context.Context
+		expectedValue interface{}
+	}{
+		{"sets a value", func(r *http.Request) context.Context {
+			return context.WithValue(r.Context(), key{}, "request-id")
+		}, "request-id"},
+		{"keeps the context on nil", func(*http.Request) context.Context {
+			return nil
+		}, nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var have interface{}
+			mux := New()
+			mux.ContextFunc = tc.contextFunc
+			mux.HandleFunc("GET", "/", func(w http.ResponseWriter, r *http.Request) {
+				have = r.Context().Value(key{})
+			})
+			mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+			if want := tc.expectedValue; have != want {
+				t.Errorf("expected context value %v, found %v", want, have)
+			}
+		})
+	}
+}
+
+func TestReset(t *testing.T) {
+	t.Run("removes all routes", func(t *testing.T) {
+		mux := New()
+		mux.Handle("GET", "/some/path", serve(200))
+		mux.Handle("POST", "/other/path", serve(201))
+		mux.HandlePort("8080", "GET", "/port", serve(202))
+		mux.HandleNotFound("/", serve(450))
+
+		mux.Reset()
+
+		for _, req := range []*http.Request{
+			httptest.NewRequest("GET", "/some/path", nil),
+			httptest.NewRequest("POST", "/other/path", nil),
+			httptest.NewRequest("GET", "http://example.com:8080/port", nil),
+		} {
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, req)
+			if want, have := 404, rw.Code; have != want {
+				t.Errorf("%s %s: expected status code %d, found %d", req.Method, req.URL, want, have)
+			}
+		}
+		if routes := mux.Routes(); len(routes) != 0 {
+			t.Errorf("expected no routes, found %v", routes)
+		}
+	})
+
+	t.Run("allows registering again", func(t *testing.T) {
+		mux := New()
+		mux.Handle("GET", "/some/path", serve(200))
+		mux.Reset()
+		mux.Handle("GET", "/some/path", serve(201))
+
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, httptest.NewRequest("GET", "/some/path", nil))
+		if want, have := 201, rw.Code; have != want {
+			t.Errorf("expected status code %d, found %d", want, have)
+		}
+	})
+
+	t.Run("removes aliases", func(t *testing.T) {
+		mux := New()
+		mux.AliasMethod("READ", "GET")
+		mux.Reset()
+		mux.Handle("GET", "/some/path", serve(200))
+		mux.Handle("READ", "/some/path", serve(201))
+
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, httptest.NewRequest("READ", "/some/path", nil))
+		if want, have := 201, rw.Code; have != want {
+			t.Errorf("expected status code %d, found %d", want, have)
+		}
+	})
+}
+
+func TestCaseInsensitivePath(t *testing.T) {
+	testCases := [...]struct {
+		path            string
+		expectedCode    int
+		expectedPattern string
+	}{
+		{"/Search", 200, "/search"},
+		{"/SEARCH", 200, "/search"},
+		{"/search", 200, "/search"},
+		{"/%53earch", 200, "/search"},
+		{"/Items/Foo", 201, "/items/"},
+		{"/Items", 301, "/items/"},
+		{"/Sea", 404, ""},
+	}
+
+	mux := New()
+	mux.CaseInsensitivePath = true
+	mux.Handle("GET", "/search", serve(200))
+	mux.Handle("GET", "/Items/", serve(201))
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tc.path, nil)
+			originalPath := r.URL.Path
+			h, pattern := mux.Handler(r)
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, r)
+			if have, want := rr.Code, tc.expectedCode; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+			if have, want := pattern, tc.expectedPattern; have != want {
+				t.Errorf("expected pattern %q, found %q", want, have)
+			}
+			if have, want := r.URL.Path, originalPath; have != want {
+				t.Errorf("expected the request path to be left as %q, found %q", want, have)
+			}
+		})
+	}
+}
+
+func TestMethodMux(t *testing.T) {
+	mux := New()
+	mux.Handle("GET", "/some/path", serve(200))
+
+	t.Run("existing method", func(t *testing.T) {
+		m, ok := mux.MethodMux("GET")
+		if !ok || m == nil {
+			t.Fatalf("expected a ServeMux for GET")
+		}
+		rw := httptest.NewRecorder()
+		m.ServeHTTP(rw, httptest.NewRequest("GET", "/some/path", nil))
+		if want, have := 200, rw.Code; have != want {
+			t.Errorf("expected status code %d, found %d", want, have)
+		}
+	})
+
+	t.Run("missing method", func(t *testing.T) {
+		if m, ok := mux.MethodMux("POST"); ok || m != nil {
+			t.Errorf("expected no ServeMux for POST, found %v", m)
+		}
+	})
+}
+
+func TestPreserveMethodOnRedirect(t *testing.T) {
+	testCases := [...]struct {
+		name         string
+		preserve     bool
+		redirectCode int
+		expectedCode int
+	}{
+		{"disabled", false, 0, 301},
+		{"enabled", true, 0, 308},
+		{"overridden by RedirectCode", true, 307, 307},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mux := New()
+			mux.PreserveMethodOnRedirect = tc.preserve
+			mux.RedirectCode = tc.redirectCode
+			mux.Handle("POST", "/dir/", serve(200))
+
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest("POST", "/dir?a=1", strings.NewReader("body")))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+			if want, have := "/dir/?a=1", rw.Header().Get("Location"); have != want {
+				t.Errorf("expected Location %q, found %q", want, have)
+			}
+		})
+	}
+}
+
+func TestSubtreeOnly404(t *testing.T) {
+	testCases := [...]struct {
+		subtreeOnly404 bool
+		method         string
+		path           string
+		expectedCode   int
+	}{
+		{false, "POST", "/a/b", 405},
+		{false, "POST", "/a/", 405},
+		{false, "POST", "/exact", 405},
+		{true, "POST", "/a/b", 404},
+		{true, "POST", "/a/", 405},
+		{true, "POST", "/a", 405},
+		{true, "POST", "/exact", 405},
+		{true, "GET", "/a/b", 200},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%t %s %s", tc.subtreeOnly404, tc.method, tc.path), func(t *testing.T) {
+			mux := New()
+			mux.SubtreeOnly404 = tc.subtreeOnly404
+			mux.Handle("GET", "/a/", serve(200))
+			mux.Handle("GET", "/exact", serve(200))
+
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest(tc.method, tc.path, nil))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+
+	t.Run("zero value", func(t *testing.T) {
+		var mux ServeMux
+		mux.Handle("GET", "/a/", serve(200))
+
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, httptest.NewRequest("POST", "/a/b", nil))
+		if want, have := 405, rw.Code; have != want {
+			t.Errorf("expected status code %d, found %d", want, have)
+		}
+	})
+}
+
+func TestOnRegister(t *testing.T) {
+	mux := New()
+	mux.OnRegister = func(method, pattern string) (string, string, error) {
+		if strings.ToLower(pattern) != pattern {
+			return "", "", fmt.Errorf("pattern %q is not lowercase", pattern)
+		}
+		if !strings.HasSuffix(pattern, "/") {
+			pattern += "/"
+		}
+		return method, pattern, nil
+	}
+
+	t.Run("rewrites the pattern", func(t *testing.T) {
+		mux.Handle("GET", "/items", serve(200))
+
+		_, pattern := mux.Handler(httptest.NewRequest("GET", "/items/", nil))
+		if want, have := "/items/", pattern; have != want {
+			t.Errorf("expected pattern %q, found %q", want, have)
+		}
+	})
+
+	t.Run("rejects the pattern", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected Handle to panic")
+			}
+		}()
+		mux.Handle("GET", "/Items", serve(200))
+	})
+
+	t.Run("returns the error from HandleBatch", func(t *testing.T) {
+		err := mux.HandleBatch([]Route{
+			{Method: "GET", Pattern: "/users", Handler: serve(200)},
+			{Method: "GET", Pattern: "/Users", Handler: serve(200)},
+		})
+		if err == nil {
+			t.Errorf("expected an error")
+		}
+		if want, have := 1, len(mux.Routes()); have != want {
+			t.Errorf("expected %d route, found %d", want, have)
+		}
+	})
+}
+
+func TestSetRequestPattern(t *testing.T) {
+	testCases := [...]struct {
+		setRequestPattern bool
+		expectedPattern   string
+	}{
+		{false, ""},
+		{true, "/items/"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%t", tc.setRequestPattern), func(t *testing.T) {
+			var have string
+			mux := New()
+			mux.SetRequestPattern = tc.setRequestPattern
+			mux.HandleFunc("GET", "/items/", func(w http.ResponseWriter, r *http.Request) {
+				have = r.Pattern
+			})
+			mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/items/42", nil))
+			if want := tc.expectedPattern; have != want {
+				t.Errorf("expected r.Pattern %q, found %q", want, have)
+			}
+		})
+	}
+}
+
+func TestOnServed(t *testing.T) {
+	testCases := [...]struct {
+		method          string
+		path            string
+		expectedPattern string
+		expectedStatus  int
+	}{
+		{"GET", "/items/42", "/items/", 200},
+		{"POST", "/items/", "/items/", 201},
+		{"DELETE", "/items/42", "", 405},
+		{"GET", "/missing", "", 404},
+	}
+
+	mux := New()
+	mux.HandleFunc("GET", "/items/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("item"))
+	})
+	mux.Handle("POST", "/items/", serve(201))
+
+	for _, tc := range testCases {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			var (
+				called  bool
+				pattern string
+				status  int
+			)
+			mux.OnServed = func(r *http.Request, p string, s int) {
+				called, pattern, status = true, p, s
+			}
+			mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tc.method, tc.path, nil))
+			if !called {
+				t.Fatalf("expected OnServed to be called")
+			}
+			if want, have := tc.expectedPattern, pattern; have != want {
+				t.Errorf("expected pattern %q, found %q", want, have)
+			}
+			if want, have := tc.expectedStatus, status; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+}
+
+func TestInterceptor(t *testing.T) {
+	mux := New()
+	mux.Handle("GET", "/items", serve(200))
+	mux.Interceptor = func(r *http.Request, matched http.Handler, pattern string) http.Handler {
+		if r.Header.Get("X-Variant") == "b" && pattern == "/items" {
+			return serve(299)
+		}
+		return matched
+	}
+
+	testCases := [...]struct {
+		variant      string
+		path         string
+		expectedCode int
+	}{
+		{"", "/items", 200},
+		{"b", "/items", 299},
+		{"b", "/missing", 404},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.variant+" "+tc.path, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.path, nil)
+			req.Header.Set("X-Variant", tc.variant)
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, req)
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+}
+
+func TestPublicMethods(t *testing.T) {
+	testCases := [...]struct {
+		publicMethods []string
+		method        string
+		path          string
+		expectedCode  int
+	}{
+		{nil, "POST", "/internal", 405},
+		{nil, "POST", "/public", 405},
+		{[]string{"GET"}, "POST", "/internal", 404},
+		{[]string{"GET"}, "POST", "/public", 405},
+		{[]string{"GET"}, "PURGE", "/internal", 200},
+	}
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%v %s %s", tc.publicMethods, tc.method, tc.path), func(t *testing.T) {
+			mux := New()
+			mux.PublicMethods = tc.publicMethods
+			mux.Handle("GET", "/public", serve(200))
+			mux.Handle("PURGE", "/internal", serve(200))
+
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest(tc.method, tc.path, nil))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+}
+
+func TestPatternConsistency(t *testing.T) {
+	testCases := [...]struct {
+		method          string
+		host            string
+		path            string
+		expectedCode    int
+		expectedPattern string
+	}{
+		{"CONNECT", "example.com", "/dir", 301, "/dir/"},
+		{"CONNECT", "example.com", "/dir/", 200, "/dir/"},
+		{"CONNECT", "example.com", "/dir/..", 200, "/dir/"},
+		{"CONNECT", "example.com", "/dir/./file", 200, "/dir/"},
+		{"CONNECT", "example.com", "/../search", 404, ""},
+		{"CONNECT", "sub.example.com", "/dir", 301, "sub.example.com/dir/"},
+		{"CONNECT", "sub.example.com", "/dir/..", 202, "sub.example.com/dir/"},
+		{"GET", "sub.example.com", "/dir", 301, "sub.example.com/dir/"},
+		{"GET", "sub.example.com", "/dir/../dir/x", 301, "sub.example.com/dir/"},
+		{"GET", "example.com", "/dir", 405, ""},
+	}
+
+	codes := map[string]int{
+		"/dir/":                200,
+		"sub.example.com/dir/": 202,
+	}
+
+	mux := New()
+	mux.Handle("CONNECT", "/dir/", serve(codes["/dir/"]))
+	mux.Handle("CONNECT", "sub.example.com/dir/", serve(codes["sub.example.com/dir/"]))
+	mux.Handle("GET", "sub.example.com/dir/", serve(codes["sub.example.com/dir/"]))
+
+	for _, tc := range testCases {
+		t.Run(fmt.Sprintf("%s %s%s", tc.method, tc.host, tc.path), func(t *testing.T) {
+			r := &http.Request{Method: tc.method, Host: tc.host, URL: &url.URL{Path: tc.path}}
+			h, pattern := mux.Handler(r)
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, r)
+			if have, want := rr.Code, tc.expectedCode; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+			if have, want := pattern, tc.expectedPattern; have != want {
+				t.Errorf("expected pattern %q, found %q", want, have)
+			}
+
+			switch {
+			case rr.Code == 301:
+				// The pattern is the one serving the redirect target.
+				location, err := url.Parse(rr.Header().Get("Location"))
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				rr := httptest.NewRecorder()
+				r := &http.Request{Method: tc.method, Host: tc.host, URL: location}
+				h, targetPattern := mux.Handler(r)
+				h.ServeHTTP(rr, r)
+				if targetPattern != pattern {
+					t.Errorf("expected the redirect target to match %q, found %q", pattern, targetPattern)
+				}
+				if have, want := rr.Code, codes[pattern]; have != want {
+					t.Errorf("expected the redirect target to be served with %d, found %d", want, have)
+				}
+			case pattern != "":
+				// The pattern is the one of the serving handler.
+				if have, want := rr.Code, codes[pattern]; have != want {
+					t.Errorf("expected the handler of %q to serve with %d, found %d", pattern, want, have)
+				}
+			}
+		})
+	}
+}
+
+func TestKnownHosts(t *testing.T) {
+	testCases := [...]struct {
+		host         string
+		path         string
+		expectedCode int
+	}{
+		{"example.com", "/site", 200},
+		{"example.com:8443", "/site", 200},
+		{"api.example.com", "/v1", 201},
+		{"api.example.com", "/missing", 404},
+		{"other.com", "/site", 421},
+		{"other.com", "/health", 202},
+		{"other.com", "/missing", 421},
+	}
+
+	mux := New()
+	mux.KnownHosts = []string{"example.com", "api.example.com"}
+	mux.Handle("GET", "example.com/site", serve(200))
+	mux.Handle("GET", "api.example.com/v1", serve(201))
+	mux.Handle("GET", "/health", serve(202))
+
+	for _, tc := range testCases {
+		t.Run(tc.host+tc.path, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest("GET", "http://"+tc.host+tc.path, nil))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+}
+
+func TestMethodNotAllowedAllow(t *testing.T) {
+	h := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+
+	testCases := [...]struct {
+		name            string
+		verbose         bool
+		path            string
+		expectedAllow   string
+		expectedPattern string
+	}{
+		{"two patterns", false, "/dir/file", "GET, POST", ""},
+		{"one pattern", false, "/other", "PUT", ""},
+		{"verbose", true, "/dir/file", "GET, POST", "GET /dir/, POST /dir/file"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mux := New()
+			mux.VerboseAllow = tc.verbose
+			mux.Handle("POST", "/dir/file", h)
+			mux.Handle("GET", "/dir/", h)
+			mux.Handle("PUT", "/other", h)
+			mux.Handle("PUT", "/other/", h)
+
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest("DELETE", tc.path, nil))
+			if want, have := 405, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+			if want, have := tc.expectedAllow, rw.Header().Get("Allow"); have != want {
+				t.Errorf("expected Allow %q, found %q", want, have)
+			}
+			if want, have := tc.expectedPattern, rw.Header().Get("X-Allowed-Patterns"); have != want {
+				t.Errorf("expected X-Allowed-Patterns %q, found %q", want, have)
+			}
+		})
+	}
+}
+
+func TestMethodCheckBeforeRedirect(t *testing.T) {
+	testCases := [...]struct {
+		name         string
+		checkFirst   bool
+		method       string
+		path         string
+		expectedCode int
+	}{
+		{"redirect first", false, "POST", "/dir", 301},
+		{"method check first", true, "POST", "/dir", 405},
+		{"method check first, no other method", true, "POST", "/only-post", 301},
+		{"method check first, other method redirects too", true, "POST", "/both", 301},
+		{"method check first, exact match", true, "GET", "/dir", 200},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mux := New()
+			mux.MethodCheckBeforeRedirect = tc.checkFirst
+			mux.Handle("POST", "/dir/", serve(201))
+			mux.Handle("GET", "/dir", serve(200))
+			mux.Handle("POST", "/only-post/", serve(201))
+			mux.Handle("POST", "/both/", serve(201))
+			mux.Handle("GET", "/both/", serve(200))
+
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest(tc.method, tc.path, nil))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+			if tc.expectedCode == 405 && rw.Header().Get("Allow") != "GET" {
+				t.Errorf("expected \"Allow: GET\", found %q", rw.Header().Get("Allow"))
+			}
+		})
+	}
+}
+
+func TestBuild(t *testing.T) {
+	testCases := [...]struct {
+		method       string
+		path         string
+		expectedCode int
+	}{
+		{"GET", "/items/1", 200},
+		{"DELETE", "/items/1", 405},
+		{"DELETE", "/items", 405},
+		{"DELETE", "/missing", 404},
+		{"POST", "/late", 405},
+	}
+
+	for _, build := range [...]bool{false, true} {
+		t.Run(fmt.Sprintf("build=%t", build), func(t *testing.T) {
+			mux := New()
+			mux.Handle("GET", "/items/", serve(200))
+			mux.Handle("PUT", "/items/1", serve(201))
+			if build {
+				mux.Build()
+			}
+			mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("DELETE", "/missing", nil))
+			mux.Handle("GET", "/late", serve(200))
+
+			for _, tc := range testCases {
+				rw := httptest.NewRecorder()
+				mux.ServeHTTP(rw, httptest.NewRequest(tc.method, tc.path, nil))
+				if want, have := tc.expectedCode, rw.Code; have != want {
+					t.Errorf("%s %s: expected status code %d, found %d", tc.method, tc.path, want, have)
+				}
+			}
+		})
+	}
+}
+
+func TestNoRedirect(t *testing.T) {
+	testCases := [...]struct {
+		method       string
+		path         string
+		expectedCode int
+	}{
+		{"GET", "/dir/file", 200},
+		{"GET", "/dir/./file", 404},
+		{"GET", "/../dir/", 404},
+		{"GET", "/dir//file", 404},
+		{"GET", "/dir", 404},
+		{"POST", "/dir", 404},
+		{"POST", "/dir/file", 405},
+		{"POST", "/dir/./file", 404},
+	}
+
+	mux := New()
+	mux.NoRedirect = true
+	mux.Handle("GET", "/dir/", serve(200))
+	mux.Handle("POST", "/other", serve(201))
+
+	for _, tc := range testCases {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			r := &http.Request{Method: tc.method, Host: "example.com", URL: &url.URL{Path: tc.path}}
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, r)
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+			if location := rw.Header().Get("Location"); location != "" {
+				t.Errorf("expected no redirect, found Location %q", location)
+			}
+		})
+	}
 }
 
-func TestHandleFunc(t *testing.T) {
-	t.Run("registers a handler", func(t *testing.T) {
-		req := httptest.NewRequest("DELETE", "/some/path", nil)
-		rw := &httptest.ResponseRecorder{}
-		s := New()
-		s.HandleFunc("DELETE", "/some/path", func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(512)
+func TestServerHeader(t *testing.T) {
+	testCases := [...]struct {
+		name           string
+		method         string
+		target         string
+		expectedCode   int
+		expectedServer string
+	}{
+		{"matched", "GET", "/items", 200, "methodmux"},
+		{"overridden", "GET", "/custom", 200, "custom"},
+		{"not found", "GET", "/missing", 404, "methodmux"},
+		{"method not allowed", "POST", "/items", 405, "methodmux"},
+		{"bad request", "OPTIONS", "*", 400, "methodmux"},
+	}
+
+	mux := New()
+	mux.ServerHeader = "methodmux"
+	mux.Handle("GET", "/items", serve(200))
+	mux.HandleFunc("GET", "/custom", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Server", "custom")
+	})
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest(tc.method, tc.target, nil))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+			if want, have := tc.expectedServer, rw.Header().Get("Server"); have != want {
+				t.Errorf("expected \"Server: %s\", found %q", want, have)
+			}
 		})
-		s.ServeHTTP(rw, req)
-		if want, have := 512, rw.Code; have != want {
+	}
+}
+
+func TestConcurrentUse(t *testing.T) {
+	const (
+		writers = 4
+		readers = 8
+		rounds  = 200
+	)
+
+	mux := New()
+	mux.Handle("GET", "/", serve(200))
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				pattern := fmt.Sprintf("/w%d/%d", w, i)
+				mux.Handle("GET", pattern, serve(201))
+				mux.HandleQuery("POST", pattern, "a", "1", serve(202))
+				mux.Swap("GET", pattern, serve(203))
+				mux.HandleParam("PUT", pattern+"/{id}", serve(204))
+				mux.SetRouteEnabled("GET", pattern, i%2 == 0)
+				if i%3 == 0 {
+					mux.Deregister("GET", pattern)
+				}
+				if i%50 == 0 {
+					mux.Build()
+				}
+			}
+		}(w)
+	}
+
+	for r := 0; r < readers; r++ {
+		wg.Add(1)
+		go func(r int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				path := fmt.Sprintf("/w%d/%d", r%writers, i)
+				for _, method := range []string{"GET", "POST", "PUT", "DELETE"} {
+					req := httptest.NewRequest(method, path+"?a=1", nil)
+					mux.ServeHTTP(httptest.NewRecorder(), req)
+					mux.Handler(req)
+				}
+				mux.Routes()
+				mux.Len()
+				mux.Stats()
+				mux.Validate()
+				mux.Explain(httptest.NewRequest("GET", path, nil))
+			}
+		}(r)
+	}
+
+	wg.Wait()
+
+	// Each writer leaves its GET routes but every third one, and all
+	// its conditional POST routes.
+	deregistered := (rounds + 2) / 3
+	if want, have := 1+writers*(2*rounds-deregistered), mux.Len(); have != want {
+		t.Errorf("expected %d routes, found %d", want, have)
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	testCases := [...]struct {
+		name             string
+		canonicalize     func(string) string
+		path             string
+		expectedCode     int
+		expectedLocation string
+	}{
+		{"default", nil, "/dir/./file", 301, "/dir/file"},
+		{"literal", func(p string) string { return p }, "/dir/./file", 200, ""},
+		{"literal double slash", func(p string) string { return p }, "/dir//file", 200, ""},
+		{"literal dot dot", func(p string) string { return p }, "/../search", 404, ""},
+		{"literal trailing slash", func(p string) string { return p }, "/dir", 301, "/dir/"},
+		{"literal exact", func(p string) string { return p }, "/search", 201, ""},
+		{"custom", func(p string) string { return strings.TrimSuffix(p, ".html") }, "/search.html?q=a", 301, "/search?q=a"},
+		{"custom on host", func(p string) string { return p }, "/sub", 202, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mux := New()
+			mux.Canonicalize = tc.canonicalize
+			mux.Handle("GET", "/dir/", serve(200))
+			mux.Handle("GET", "/search", serve(201))
+			mux.Handle("GET", "example.com:8080/sub", serve(203))
+			mux.Handle("GET", "example.com/sub", serve(202))
+
+			req := httptest.NewRequest("GET", tc.path, nil)
+			req.Host = "example.com:8080"
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, req)
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+			if want, have := tc.expectedLocation, rw.Header().Get("Location"); have != want {
+				t.Errorf("expected Location %q, found %q", want, have)
+			}
+		})
+	}
+}
+
+func TestRawHandler(t *testing.T) {
+	testCases := [...]struct {
+		method             string
+		host               string
+		path               string
+		expectedCode       int
+		expectedPattern    string
+		expectedIsRedirect bool
+	}{
+		{"GET", "example.com", "/dir", 200, "/dir/", true},
+		{"GET", "example.com", "/dir/", 200, "/dir/", false},
+		{"GET", "example.com", "/../dir", 200, "/dir/", true},
+		{"GET", "sub.example.com", "/search", 202, "sub.example.com/search/", true},
+		{"POST", "example.com", "/dir", 405, "", false},
+		{"GET", "example.com", "/missing", 404, "", false},
+	}
+
+	mux := New()
+	mux.Handle("GET", "/dir/", serve(200))
+	mux.Handle("GET", "sub.example.com/search/", serve(202))
+	mux.Handle("PUT", "/dir/", serve(201))
+	mux.Handle("PUT", "/dir", serve(201))
+
+	for _, tc := range testCases {
+		t.Run(tc.method+" "+tc.host+tc.path, func(t *testing.T) {
+			h, pattern, isRedirect := mux.RawHandler(tc.method, tc.host, tc.path)
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, httptest.NewRequest(tc.method, "/", nil))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+			if want, have := tc.expectedPattern, pattern; have != want {
+				t.Errorf("expected pattern %q, found %q", want, have)
+			}
+			if want, have := tc.expectedIsRedirect, isRedirect; have != want {
+				t.Errorf("expected isRedirect to be %t, found %t", want, have)
+			}
+		})
+	}
+}
+
+func TestStrictAbsoluteForm(t *testing.T) {
+	testCases := [...]struct {
+		name         string
+		strict       bool
+		target       string
+		host         string
+		expectedCode int
+	}{
+		{"origin form", true, "/x", "example.com", 200},
+		{"consistent absolute form", true, "http://example.com/x", "example.com", 200},
+		{"consistent absolute form, case", true, "http://EXAMPLE.com/x", "example.com", 200},
+		{"mismatched absolute form", true, "http://other.com/x", "example.com", 400},
+		{"mismatched absolute form, not strict", false, "http://other.com/x", "example.com", 200},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mux := New()
+			mux.StrictAbsoluteForm = tc.strict
+			mux.Handle("GET", "/x", serve(200))
+
+			req := httptest.NewRequest("GET", tc.target, nil)
+			req.Host = tc.host
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, req)
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+
+	// The http.Server routes the requests in absolute form by their URL
+	// host, which leaves no mismatch to reject.
+	t.Run("http.Server", func(t *testing.T) {
+		mux := New()
+		mux.StrictAbsoluteForm = true
+		mux.HandleFunc("GET", "/x", func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, r.Host)
+		})
+		srv := httptest.NewServer(mux)
+		defer srv.Close()
+
+		conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer conn.Close()
+		io.WriteString(conn, "GET http://other.com/x HTTP/1.1\r\nHost: example.com\r\nConnection: close\r\n\r\n")
+
+		res, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		if want, have := 200, res.StatusCode; have != want {
+			t.Errorf("expected status code %d, found %d", want, have)
+		}
+		if want, have := "other.com", string(body); have != want {
+			t.Errorf("expected r.Host %q, found %q", want, have)
+		}
+	})
+}
+
+func TestFast404(t *testing.T) {
+	testCases := [...]struct {
+		name         string
+		fast404      bool
+		method       string
+		path         string
+		expectedCode int
+	}{
+		{"match", true, "GET", "/items", 200},
+		{"wrong method", false, "POST", "/items", 405},
+		{"wrong method, Fast404", true, "POST", "/items", 404},
+		{"missing, Fast404", true, "GET", "/missing", 404},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mux := New()
+			mux.Fast404 = tc.fast404
+			mux.Handle("GET", "/items", serve(200))
+			mux.Handle("PUT", "/other", serve(200))
+
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest(tc.method, tc.path, nil))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+}
+
+func TestStrictPatterns(t *testing.T) {
+	testCases := [...]struct {
+		pattern       string
+		expectedPanic bool
+	}{
+		{"x", true},
+		{"/x", false},
+		{"example.com/x", false},
+		{"example.com/", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pattern, func(t *testing.T) {
+			mux := New()
+			mux.StrictPatterns = true
+
+			defer func() {
+				if have, want := recover() != nil, tc.expectedPanic; have != want {
+					t.Errorf("expected panic to be %t, found %t", want, have)
+				}
+			}()
+			mux.Handle("GET", tc.pattern, serve(200))
+		})
+	}
+
+	t.Run("HandleBatch", func(t *testing.T) {
+		mux := New()
+		mux.StrictPatterns = true
+		err := mux.HandleBatch([]Route{{Method: "GET", Pattern: "x", Handler: serve(200)}})
+		if err == nil {
+			t.Errorf("expected an error")
+		}
+	})
+
+	t.Run("not strict", func(t *testing.T) {
+		New().Handle("GET", "x", serve(200))
+	})
+}
+
+func TestMethodsForPath(t *testing.T) {
+	testCases := [...]struct {
+		host            string
+		path            string
+		expectedMethods []string
+	}{
+		{"example.com", "/x", []string{"GET", "POST"}},
+		{"example.com", "/y", []string{"DELETE"}},
+		{"example.com", "/y/z", []string{"DELETE"}},
+		{"admin.example.com", "/y", []string{"DELETE", "PUT"}},
+		{"example.com", "/missing", nil},
+	}
+
+	mux := New()
+	mux.Handle("GET", "/x", serve(200))
+	mux.Handle("POST", "/x", serve(200))
+	mux.Handle("DELETE", "/y/", serve(200))
+	mux.Handle("PUT", "admin.example.com/y", serve(200))
+
+	for _, tc := range testCases {
+		t.Run(tc.host+tc.path, func(t *testing.T) {
+			if want, have := tc.expectedMethods, mux.MethodsForPath(tc.host, tc.path); !reflect.DeepEqual(have, want) {
+				t.Errorf("expected methods %q, found %q", want, have)
+			}
+		})
+	}
+}
+
+func TestPreFilter(t *testing.T) {
+	testCases := [...]struct {
+		userAgent    string
+		path         string
+		expectedCode int
+	}{
+		{"curl/8.0", "/x", 200},
+		{"BadBot/1.0", "/x", 403},
+		{"BadBot/1.0", "/missing", 403},
+		{"curl/8.0", "/missing", 404},
+	}
+
+	var routed int
+	mux := New()
+	mux.PreFilter = func(w http.ResponseWriter, r *http.Request) bool {
+		if strings.HasPrefix(r.UserAgent(), "BadBot/") {
+			ForbiddenHandler.ServeHTTP(w, r)
+			return false
+		}
+		return true
+	}
+	mux.Handle("GET", "/x", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		routed++
+	}))
+
+	for _, tc := range testCases {
+		t.Run(tc.userAgent+" "+tc.path, func(t *testing.T) {
+			routed = 0
+			req := httptest.NewRequest("GET", tc.path, nil)
+			req.Header.Set("User-Agent", tc.userAgent)
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, req)
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+			if tc.expectedCode == 403 && routed != 0 {
+				t.Errorf("expected the filtered request not to be routed")
+			}
+		})
+	}
+}
+
+func TestNoRedirectForJSON(t *testing.T) {
+	testCases := [...]struct {
+		name             string
+		accept           string
+		path             string
+		expectedCode     int
+		expectedLocation string
+		expectedPath     string
+		expectedVary     string
+	}{
+		{"JSON", "application/json", "/dir", 200, "", "/dir/", "Accept"},
+		{"JSON suffix", "application/problem+json", "/dir", 200, "", "/dir/", "Accept"},
+		{"JSON preferred", "text/html;q=0.5, application/json", "/a/../dir", 200, "", "/dir/", "Accept"},
+		{"JSON not preferred", "text/html, application/json;q=0.9", "/dir", 301, "/dir/", "", "Accept"},
+		{"HTML", "text/html", "/dir", 301, "/dir/", "", "Accept"},
+		{"no Accept", "", "/dir", 301, "/dir/", "", "Accept"},
+		{"JSON canonical path", "application/json", "/dir/", 200, "", "/dir/", ""},
+		{"JSON not found", "application/json", "/missing", 404, "", "", ""},
+	}
+
+	var served string
+	mux := New()
+	mux.NoRedirectForJSON = true
+	mux.HandleFunc("GET", "/dir/", func(w http.ResponseWriter, r *http.Request) {
+		served = r.URL.Path
+	})
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			served = ""
+			req := httptest.NewRequest("GET", tc.path, nil)
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, req)
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+			if want, have := tc.expectedLocation, rw.Header().Get("Location"); have != want {
+				t.Errorf("expected Location %q, found %q", want, have)
+			}
+			if want, have := tc.expectedVary, rw.Header().Get("Vary"); have != want {
+				t.Errorf("expected Vary %q, found %q", want, have)
+			}
+			if want, have := tc.expectedPath, served; have != want {
+				t.Errorf("expected the handler to see path %q, found %q", want, have)
+			}
+		})
+	}
+}
+
+func TestOnSlow(t *testing.T) {
+	testCases := [...]struct {
+		name            string
+		threshold       time.Duration
+		path            string
+		expectedSlow    bool
+		expectedPattern string
+	}{
+		{"slow", 25 * time.Millisecond, "/slow", true, "/slow"},
+		{"fast", time.Hour, "/slow", false, ""},
+		{"disabled", 0, "/slow", false, ""},
+		{"fast handler", 25 * time.Millisecond, "/fast", false, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				slow     bool
+				pattern  string
+				duration time.Duration
+			)
+			mux := New()
+			mux.SlowThreshold = tc.threshold
+			mux.OnSlow = func(_ *http.Request, p string, d time.Duration) {
+				slow, pattern, duration = true, p, d
+			}
+			mux.HandleFunc("GET", "/slow", func(http.ResponseWriter, *http.Request) {
+				time.Sleep(50 * time.Millisecond)
+			})
+			mux.Handle("GET", "/fast", serve(200))
+
+			mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tc.path, nil))
+			if want, have := tc.expectedSlow, slow; have != want {
+				t.Fatalf("expected OnSlow to be called: %t, found %t", want, have)
+			}
+			if want, have := tc.expectedPattern, pattern; have != want {
+				t.Errorf("expected pattern %q, found %q", want, have)
+			}
+			if slow && duration < 50*time.Millisecond {
+				t.Errorf("expected a duration of at least 50ms, found %s", duration)
+			}
+		})
+	}
+}
+
+func TestFragment(t *testing.T) {
+	testCases := [...]struct {
+		name            string
+		url             *url.URL
+		expectedCode    int
+		expectedPattern string
+	}{
+		{"literal fragment", &url.URL{Path: "/x#frag", RawPath: "/x#frag"}, 200, "/x"},
+		{"literal fragment after an encoded slash", &url.URL{Path: "/x/a/b#frag", RawPath: "/x/a%2Fb#frag"}, 201, "/x/"},
+		{"encoded hash", &url.URL{Path: "/x#frag"}, 404, ""},
+		{"fragment field", &url.URL{Path: "/x#frag", Fragment: "frag"}, 200, "/x"},
+		{"redirect", &url.URL{Path: "/dir#frag", RawPath: "/dir#frag"}, 301, "/dir/"},
+	}
+
+	mux := New()
+	mux.Handle("GET", "/x", serve(200))
+	mux.Handle("GET", "/x/", serve(201))
+	mux.Handle("GET", "/dir/", serve(200))
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &http.Request{Method: "GET", Host: "localhost", URL: tc.url, Header: make(http.Header)}
+			h, pattern := mux.Handler(req)
+			if want, have := tc.expectedPattern, pattern; have != want {
+				t.Errorf("expected pattern %q, found %q", want, have)
+			}
+			rw := httptest.NewRecorder()
+			h.ServeHTTP(rw, req)
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+
+	t.Run("request target", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, httptest.NewRequest("GET", "/x#frag", nil))
+		if want, have := 200, rw.Code; have != want {
 			t.Errorf("expected status code %d, found %d", want, have)
 		}
 	})
 }
 
+func TestBuildConflictingPatterns(t *testing.T) {
+	// The patterns conflict across methods under the Go 1.22 pattern
+	// rules, which the index must not trip on. The paths match the
+	// patterns under both the Go 1.21 and the Go 1.22 rules.
+	testCases := [...]struct {
+		method       string
+		path         string
+		expectedCode int
+	}{
+		{"GET", "/items/{id}", 200},
+		{"DELETE", "/items/{id}", 405},
+		{"DELETE", "/{a}/x", 405},
+		{"DELETE", "/missing", 404},
+	}
+
+	for _, build := range [...]bool{false, true} {
+		t.Run(fmt.Sprintf("build=%t", build), func(t *testing.T) {
+			mux := New()
+			mux.Handle("GET", "/items/{id}", serve(200))
+			mux.Handle("POST", "/items/{name}", serve(201))
+			mux.Handle("PUT", "/{a}/x", serve(200))
+			mux.Handle("POST", "/y/{b}", serve(201))
+			if build {
+				mux.Build()
+			}
+
+			for _, tc := range testCases {
+				rw := httptest.NewRecorder()
+				mux.ServeHTTP(rw, httptest.NewRequest(tc.method, tc.path, nil))
+				if want, have := tc.expectedCode, rw.Code; have != want {
+					t.Errorf("%s %s: expected status code %d, found %d", tc.method, tc.path, want, have)
+				}
+			}
+			if methods := mux.MethodsForPath("localhost", "/y/{b}"); len(methods) == 0 {
+				t.Errorf("expected methods for /y/{b}, found none")
+			}
+		})
+	}
+}
+
+func TestIndexCanonicalize(t *testing.T) {
+	testCases := [...]struct {
+		name         string
+		canonicalize func(string) string
+		pattern      string
+		method       string
+		path         string
+		expectedCode int
+	}{
+		{"lower case", strings.ToLower, "/foo", "GET", "/FOO", 301},
+		{"lower case, other method", strings.ToLower, "/foo", "POST", "/FOO", 405},
+		{"identity", func(p string) string { return p }, "/a/", "GET", "/a/../b", 200},
+		{"identity, other method", func(p string) string { return p }, "/a/", "POST", "/a/../b", 405},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mux := New()
+			mux.Canonicalize = tc.canonicalize
+			mux.Handle("GET", tc.pattern, serve(200))
+			mux.Handle("PUT", "/other", serve(200))
+			mux.Build()
+
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest(tc.method, tc.path, nil))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+}
+
 func BenchmarkServeMux(b *testing.B) {
 	type test struct {
 		method string
@@ -229,3 +1682,109 @@ func BenchmarkServeMux(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkServeMuxMiss(b *testing.B) {
+	build := func(fast404 bool, methods ...string) *ServeMux {
+		mux := New()
+		mux.Fast404 = fast404
+		for _, m := range methods {
+			for i := 0; i < 50; i++ {
+				mux.Handle(m, fmt.Sprintf("/items/%d/", i), serve(200))
+			}
+		}
+		return mux
+	}
+
+	// A port-scoped pattern for another port disables the
+	// single-method fast path without matching the request, which
+	// measures the full scan it replaces.
+	baseline := build(false, "GET")
+	baseline.HandlePort("8443", "GET", "/admin/", serve(200))
+
+	benchmarks := [...]struct {
+		name string
+		mux  *ServeMux
+	}{
+		{"single method, full scan", baseline},
+		{"single method", build(false, "GET")},
+		{"two methods", build(false, "GET", "POST")},
+		{"five methods", build(false, "GET", "POST", "PUT", "PATCH", "DELETE")},
+		{"five methods, Fast404", build(true, "GET", "POST", "PUT", "PATCH", "DELETE")},
+	}
+
+	req := &http.Request{Method: "GET", Host: "localhost", URL: &url.URL{Path: "/missing"}}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, pattern := bm.mux.Handler(req); pattern != "" {
+					b.Fatalf("expected no match, found %q", pattern)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkFirstMiss(b *testing.B) {
+	methods := []string{"GET", "POST", "PUT", "PATCH", "DELETE"}
+	req := &http.Request{Method: "OPTIONS", Host: "localhost", URL: &url.URL{Path: "/missing"}}
+
+	for _, build := range [...]bool{false, true} {
+		b.Run(fmt.Sprintf("build=%t", build), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				mux := New()
+				for _, m := range methods {
+					for j := 0; j < 200; j++ {
+						mux.Handle(m, fmt.Sprintf("/items/%d/", j), serve(200))
+					}
+				}
+				if build {
+					mux.Build()
+				}
+				b.StartTimer()
+
+				if _, pattern := mux.Handler(req); pattern != "" {
+					b.Fatalf("expected no match, found %q", pattern)
+				}
+			}
+		})
+	}
+}
+
+func FuzzMatch(f *testing.F) {
+	mux := New()
+	mux.Handle("GET", "/", serve(200))
+	mux.Handle("GET", "/items/", serve(200))
+	mux.Handle("POST", "/items", serve(201))
+	mux.Handle("GET", "example.com/static/", serve(200))
+	mux.Handle("DELETE", "/items/{id}", serve(204))
+
+	registered := make(map[string]bool)
+	for _, route := range mux.Routes() {
+		registered[route.Method+" "+route.Pattern] = true
+	}
+
+	f.Add("GET", "example.com", "/items")
+	f.Add("POST", "example.com", "/items/../items")
+	f.Add("PUT", "example.com", "/items")
+	f.Add("GET", "example.com", "/static")
+	f.Add("DELETE", "", "//items/x")
+	f.Add("CONNECT", "example.com:443", "items")
+
+	f.Fuzz(func(t *testing.T, method, host, path string) {
+		pattern, kind := mux.Match(method, host, path)
+		switch kind {
+		case MatchRoute, MatchRedirect:
+			if !registered[method+" "+pattern] {
+				t.Errorf("expected a registered pattern for %s, found %q", method, pattern)
+			}
+		case MatchNotFound, MatchMethodNotAllowed:
+			if pattern != "" {
+				t.Errorf("expected no pattern, found %q", pattern)
+			}
+		default:
+			t.Errorf("unexpected match kind %d", kind)
+		}
+	})
+}
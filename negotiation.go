@@ -0,0 +1,252 @@
+package methodmux
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// HandleLang registers, for the given method and pattern, a handler
+// that dispatches each request to the handler in byLang that best
+// matches its Accept-Language header. Language tags are compared
+// case-insensitively, and a requested tag also matches its primary
+// language: "en-US" is served by "en" if no "en-US" handler exists.
+// Requests that no handler matches are served by byLang[def]. The
+// responses carry "Accept-Language" in their Vary header.
+//
+// HandleLang panics if byLang has no handler for def.
+func (mux *ServeMux) HandleLang(method, pattern string, byLang map[string]http.Handler, def string) {
+	handlers := make(map[string]http.Handler, len(byLang))
+	for lang, h := range byLang {
+		handlers[strings.ToLower(lang)] = h
+	}
+
+	defaultHandler, ok := handlers[strings.ToLower(def)]
+	if !ok {
+		panic("methodmux: no handler for the default language " + strconv.Quote(def))
+	}
+
+	mux.Handle(method, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addVary(w.Header(), "Accept-Language")
+		for _, lang := range accepted(r.Header.Get("Accept-Language")) {
+			if h, ok := handlers[lang]; ok {
+				h.ServeHTTP(w, r)
+				return
+			}
+			if i := strings.Index(lang, "-"); i > 0 {
+				if h, ok := handlers[lang[:i]]; ok {
+					h.ServeHTTP(w, r)
+					return
+				}
+			}
+		}
+		defaultHandler.ServeHTTP(w, r)
+	}))
+}
+
+// HandleAccept registers, for the given method and pattern, a handler
+// that dispatches each request to the handler in byType that best
+// matches its Accept header, such as "application/json". Media types
+// are compared case-insensitively, and a requested range such as
+// "text/*" matches the first media type of byType in lexical order
+// with that type. Requests that no handler matches, or that accept any
+// media type with "*/*", are served by byType[def]. The responses
+// carry "Accept" in their Vary header.
+//
+// HandleAccept panics if byType has no handler for def.
+func (mux *ServeMux) HandleAccept(method, pattern string, byType map[string]http.Handler, def string) {
+	handlers := make(map[string]http.Handler, len(byType))
+	types := make([]string, 0, len(byType))
+	for mediaType, h := range byType {
+		mediaType = strings.ToLower(mediaType)
+		handlers[mediaType] = h
+		types = append(types, mediaType)
+	}
+	sort.Strings(types)
+
+	defaultHandler, ok := handlers[strings.ToLower(def)]
+	if !ok {
+		panic("methodmux: no handler for the default media type " + strconv.Quote(def))
+	}
+
+	mux.Handle(method, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addVary(w.Header(), "Accept")
+		for _, mediaRange := range accepted(r.Header.Get("Accept")) {
+			if mediaRange == "*/*" {
+				break
+			}
+			if h, ok := handlers[mediaRange]; ok {
+				h.ServeHTTP(w, r)
+				return
+			}
+			if prefix, ok := strings.CutSuffix(mediaRange, "*"); ok && strings.HasSuffix(prefix, "/") {
+				for _, mediaType := range types {
+					if strings.HasPrefix(mediaType, prefix) {
+						handlers[mediaType].ServeHTTP(w, r)
+						return
+					}
+				}
+			}
+		}
+		defaultHandler.ServeHTTP(w, r)
+	}))
+}
+
+// HandleVary registers the handler for the given method and pattern,
+// adding the given request header names to the Vary header of its
+// responses, so that caches tell apart the responses that depend on
+// them. Names already listed are not repeated.
+func (mux *ServeMux) HandleVary(method, pattern string, vary []string, handler http.Handler) {
+	vary = append([]string(nil), vary...)
+
+	mux.Handle(method, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addVary(w.Header(), vary...)
+		handler.ServeHTTP(w, r)
+	}))
+}
+
+// addVary adds the given names to the Vary header of h, unless they are
+// already listed, in any case.
+func addVary(h http.Header, names ...string) {
+	for _, name := range names {
+		if !hasVary(h, name) {
+			h.Add("Vary", name)
+		}
+	}
+}
+
+// hasVary reports whether the Vary header of h lists name.
+func hasVary(h http.Header, name string) bool {
+	for _, value := range h.Values("Vary") {
+		for _, listed := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(listed), name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HandleProto registers, for the given method and pattern, a handler
+// that dispatches HTTP/1.x requests to h1 and HTTP/2 and later
+// requests to h2. If one of them is nil, the other serves every
+// request.
+func (mux *ServeMux) HandleProto(method, pattern string, h1, h2 http.Handler) {
+	if h1 == nil || h2 == nil {
+		if h1 == nil {
+			h1 = h2
+		}
+		mux.Handle(method, pattern, h1)
+		return
+	}
+
+	mux.Handle(method, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoAtLeast(2, 0) {
+			h2.ServeHTTP(w, r)
+			return
+		}
+		h1.ServeHTTP(w, r)
+	}))
+}
+
+// HandleHTTP11 registers the handler for the given method and pattern,
+// answering the HTTP/1.0 requests with 505 "HTTP Version Not
+// Supported"; see RequireHTTP11.
+func (mux *ServeMux) HandleHTTP11(method, pattern string, handler http.Handler) {
+	mux.Handle(method, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !r.ProtoAtLeast(1, 1) {
+			HTTPVersionNotSupportedHandler.ServeHTTP(w, r)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+}
+
+// HandlePush registers the handler for the given method and pattern,
+// pushing the given asset paths to the client before calling the
+// handler, if the connection supports HTTP/2 server push. Otherwise,
+// and if the client has disabled push, the assets are not pushed.
+func (mux *ServeMux) HandlePush(method, pattern string, handler http.Handler, assets ...string) {
+	mux.Handle(method, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if pusher, ok := w.(http.Pusher); ok {
+			for _, asset := range assets {
+				if err := pusher.Push(asset, nil); err != nil {
+					break
+				}
+			}
+		}
+		handler.ServeHTTP(w, r)
+	}))
+}
+
+// HandleBodyGated registers, for the given method and pattern, a
+// handler that dispatches the requests that have a body to withBody,
+// and the others to withoutBody. A request has a body if its
+// Content-Length is positive or, as for chunked requests, unknown. The
+// body is not read to decide.
+func (mux *ServeMux) HandleBodyGated(method, pattern string, withBody, withoutBody http.Handler) {
+	if withBody == nil || withoutBody == nil {
+		panic("methodmux: nil handler")
+	}
+
+	mux.Handle(method, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hasBody(r) {
+			withBody.ServeHTTP(w, r)
+			return
+		}
+		withoutBody.ServeHTTP(w, r)
+	}))
+}
+
+// hasBody reports whether r has a non-empty body, or one of unknown
+// length.
+func hasBody(r *http.Request) bool {
+	if r.Body == nil || r.Body == http.NoBody {
+		return false
+	}
+	for _, encoding := range r.TransferEncoding {
+		if encoding == "chunked" {
+			return true
+		}
+	}
+	return r.ContentLength != 0
+}
+
+// accepted parses the value of an Accept or Accept-Language header and
+// returns the lowercased media ranges or language tags, without their
+// parameters, by decreasing preference.
+func accepted(header string) []string {
+	type tag struct {
+		lang string
+		q    float64
+	}
+
+	var tags []tag
+	for _, part := range strings.Split(header, ",") {
+		lang, params, _ := strings.Cut(part, ";")
+		lang = strings.ToLower(strings.TrimSpace(lang))
+		if lang == "" || lang == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 {
+			tags = append(tags, tag{lang: lang, q: q})
+		}
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	langs := make([]string, len(tags))
+	for i, t := range tags {
+		langs[i] = t.lang
+	}
+	return langs
+}
@@ -0,0 +1,295 @@
+package methodmux_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/pierreprinetti/go-methodmux"
+)
+
+func TestHandleLang(t *testing.T) {
+	testCases := [...]struct {
+		acceptLanguage string
+		expectedCode   int
+	}{
+		{"en", 200},
+		{"en-US,en;q=0.9", 200},
+		{"fr-CH, fr;q=0.9, en;q=0.8", 201},
+		{"de;q=0.5, fr;q=0.9", 201},
+		{"de", 200},
+		{"", 200},
+	}
+
+	mux := New()
+	mux.HandleLang("GET", "/", map[string]http.Handler{
+		"en": serve(200),
+		"fr": serve(201),
+	}, "en")
+
+	for _, tc := range testCases {
+		t.Run(tc.acceptLanguage, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept-Language", tc.acceptLanguage)
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, req)
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+			if want, have := "Accept-Language", rw.Header().Get("Vary"); have != want {
+				t.Errorf("expected Vary %q, found %q", want, have)
+			}
+		})
+	}
+
+	t.Run("panics without a default handler", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected HandleLang to panic")
+			}
+		}()
+		New().HandleLang("GET", "/", map[string]http.Handler{"en": serve(200)}, "fr")
+	})
+}
+
+func TestHandleAccept(t *testing.T) {
+	testCases := [...]struct {
+		accept       string
+		expectedCode int
+	}{
+		{"application/json", 201},
+		{"Application/JSON; charset=utf-8", 201},
+		{"text/html, application/json;q=0.9", 200},
+		{"text/csv;q=0.5, application/json;q=0.9", 201},
+		{"image/*, text/*;q=0.5", 202},
+		{"image/png", 200},
+		{"*/*", 200},
+		{"", 200},
+	}
+
+	mux := New()
+	mux.HandleAccept("GET", "/", map[string]http.Handler{
+		"text/html":        serve(200),
+		"application/json": serve(201),
+		"text/csv":         serve(202),
+	}, "text/html")
+
+	for _, tc := range testCases {
+		t.Run(tc.accept, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("Accept", tc.accept)
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, req)
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+			if want, have := "Accept", rw.Header().Get("Vary"); have != want {
+				t.Errorf("expected Vary %q, found %q", want, have)
+			}
+		})
+	}
+
+	t.Run("panics without a default handler", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected HandleAccept to panic")
+			}
+		}()
+		New().HandleAccept("GET", "/", map[string]http.Handler{"text/html": serve(200)}, "application/json")
+	})
+}
+
+func TestHandleVary(t *testing.T) {
+	testCases := [...]struct {
+		name         string
+		vary         []string
+		handlerVary  string
+		expectedVary string
+	}{
+		{"names", []string{"Cookie", "Accept-Encoding"}, "", "Cookie, Accept-Encoding"},
+		{"repeated names", []string{"Cookie", "cookie"}, "", "Cookie"},
+		{"handler names", []string{"Cookie"}, "Authorization", "Cookie, Authorization"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mux := New()
+			mux.HandleVary("GET", "/", tc.vary, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				if tc.handlerVary != "" {
+					w.Header().Add("Vary", tc.handlerVary)
+				}
+			}))
+
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest("GET", "/", nil))
+			if want, have := tc.expectedVary, strings.Join(rw.Header().Values("Vary"), ", "); have != want {
+				t.Errorf("expected Vary %q, found %q", want, have)
+			}
+		})
+	}
+}
+
+func TestHandleProto(t *testing.T) {
+	h1 := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(201) })
+	h2 := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(202) })
+
+	testCases := [...]struct {
+		name         string
+		h1, h2       http.Handler
+		protoMajor   int
+		expectedCode int
+	}{
+		{"HTTP/1.1 to h1", h1, h2, 1, 201},
+		{"HTTP/2 to h2", h1, h2, 2, 202},
+		{"HTTP/1.1 with h1 only", h1, nil, 1, 201},
+		{"HTTP/2 with h1 only", h1, nil, 2, 201},
+		{"HTTP/1.1 with h2 only", nil, h2, 1, 202},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mux := New()
+			mux.HandleProto("GET", "/", tc.h1, tc.h2)
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.ProtoMajor, req.ProtoMinor = tc.protoMajor, 0
+			if tc.protoMajor == 1 {
+				req.ProtoMinor = 1
+			}
+
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, req)
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+}
+
+func TestRequireHTTP11(t *testing.T) {
+	testCases := [...]struct {
+		name         string
+		global       bool
+		path         string
+		protoMinor   int
+		expectedCode int
+	}{
+		{"HTTP/1.0", true, "/any", 0, 505},
+		{"HTTP/1.1", true, "/any", 1, 200},
+		{"HTTP/1.0 unrestricted", false, "/any", 0, 200},
+		{"HTTP/1.0 on a restricted route", false, "/stream", 0, 505},
+		{"HTTP/1.1 on a restricted route", false, "/stream", 1, 200},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mux := New()
+			mux.RequireHTTP11 = tc.global
+			mux.Handle("GET", "/any", serve(200))
+			mux.HandleHTTP11("GET", "/stream", serve(200))
+
+			req := httptest.NewRequest("GET", tc.path, nil)
+			req.Proto, req.ProtoMajor, req.ProtoMinor = fmt.Sprintf("HTTP/1.%d", tc.protoMinor), 1, tc.protoMinor
+
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, req)
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+}
+
+type pushRecorder struct {
+	*httptest.ResponseRecorder
+	pushed []string
+}
+
+func (w *pushRecorder) Push(target string, _ *http.PushOptions) error {
+	w.pushed = append(w.pushed, target)
+	return nil
+}
+
+func TestHandlePush(t *testing.T) {
+	for _, onServed := range [...]bool{false, true} {
+		var isPusher bool
+		mux := New()
+		mux.HandlePush("GET", "/", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, isPusher = w.(http.Pusher)
+		}), "/style.css", "/app.js")
+		if onServed {
+			// OnServed makes the mux wrap the ResponseWriter.
+			mux.OnServed = func(*http.Request, string, int) {}
+		}
+
+		t.Run(fmt.Sprintf("pusher, OnServed=%t", onServed), func(t *testing.T) {
+			rw := &pushRecorder{ResponseRecorder: httptest.NewRecorder()}
+			mux.ServeHTTP(rw, httptest.NewRequest("GET", "/", nil))
+			if want, have := 200, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+			if want, have := "/style.css /app.js", strings.Join(rw.pushed, " "); have != want {
+				t.Errorf("expected pushes %q, found %q", want, have)
+			}
+			if !isPusher {
+				t.Errorf("expected the handler to see an http.Pusher")
+			}
+		})
+
+		t.Run(fmt.Sprintf("no pusher, OnServed=%t", onServed), func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest("GET", "/", nil))
+			if want, have := 200, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+			if isPusher {
+				t.Errorf("expected the handler not to see an http.Pusher")
+			}
+		})
+	}
+}
+
+func TestHandleBodyGated(t *testing.T) {
+	testCases := [...]struct {
+		name          string
+		body          io.Reader
+		contentLength int64
+		chunked       bool
+		expectedCode  int
+	}{
+		{"with body", strings.NewReader("patch"), 5, false, 201},
+		{"without body", nil, 0, false, 204},
+		{"empty body", strings.NewReader(""), 0, false, 204},
+		{"chunked", strings.NewReader("patch"), -1, true, 201},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var received string
+
+			mux := New()
+			mux.HandleBodyGated("PATCH", "/items", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				received = string(b)
+				w.WriteHeader(201)
+			}), serve(204))
+
+			req := httptest.NewRequest("PATCH", "/items", tc.body)
+			req.ContentLength = tc.contentLength
+			if tc.chunked {
+				req.TransferEncoding = []string{"chunked"}
+			}
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, req)
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+			if tc.expectedCode == 201 && received != "patch" {
+				t.Errorf("expected the handler to read the whole body, found %q", received)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package methodmux
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// paramRoute is a handler registered with HandleParam.
+type paramRoute struct {
+	template string
+	segments []string // the template split on "/"; "{name}" captures
+	handler  http.Handler
+}
+
+// params returns the values captured by matching path against the
+// template of p, or nil if path does not match. If fold is set, the
+// other segments of path are compared to the template regardless of
+// case; the captured values keep their case.
+func (p paramRoute) params(path string, fold bool) map[string]string {
+	segments := strings.Split(path, "/")
+	if len(segments) != len(p.segments) {
+		return nil
+	}
+
+	params := make(map[string]string)
+	for i, segment := range p.segments {
+		if name, ok := paramName(segment); ok {
+			if segments[i] == "" {
+				return nil
+			}
+			params[name] = segments[i]
+			continue
+		}
+		if fold {
+			segments[i] = lowerASCII(segments[i])
+		}
+		if segment != segments[i] {
+			return nil
+		}
+	}
+	return params
+}
+
+// paramName returns the name of the wildcard segment "{name}".
+func paramName(segment string) (string, bool) {
+	if len(segment) < 3 || segment[0] != '{' || segment[len(segment)-1] != '}' {
+		return "", false
+	}
+	return segment[1 : len(segment)-1], true
+}
+
+type paramsKey struct{}
+
+// HandleParam registers the handler for the given method and path
+// template, such as "/items/{id}". Each "{name}" segment matches one
+// non-empty path segment, whose value the handler can read with
+// ParamFromContext. Unlike the wildcards of http.ServeMux in Go 1.22,
+// HandleParam works regardless of the Go version.
+//
+// Templates are only consulted when no pattern registered with Handle
+// matches the request, in the order they were registered. They are
+// not reported by Routes.
+func (mux *ServeMux) HandleParam(method, template string, handler http.Handler) {
+	method, template = mux.onRegister(method, template)
+
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	if handler == nil {
+		panic("methodmux: nil handler")
+	}
+
+	if !strings.HasPrefix(template, "/") {
+		panic("methodmux: invalid template " + template)
+	}
+
+	template = mux.foldPattern(template)
+	for _, p := range mux.params[method] {
+		if p.template == template {
+			panic("methodmux: multiple registrations for " + method + " " + template)
+		}
+	}
+
+	if mux.params == nil {
+		mux.params = make(map[string][]paramRoute)
+	}
+
+	mux.params[method] = append(mux.params[method], paramRoute{
+		template: template,
+		segments: strings.Split(template, "/"),
+		handler:  handler,
+	})
+}
+
+// matchParam returns the match for the first template registered with
+// HandleParam for method that matches r. If none does, the returned
+// pattern is empty. The caller must hold the read lock.
+//
+// With CaseInsensitivePath, r.URL.Path is lowercased for matching: the
+// values are then captured again from the path of the request served,
+// which keeps its case.
+func (mux *ServeMux) matchParam(method string, r *http.Request) match {
+	fold := mux.CaseInsensitivePath
+	for _, p := range mux.params[method] {
+		if params := p.params(r.URL.Path, false); params != nil {
+			p := p
+			return match{kind: MatchCustom, pattern: p.template, handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if fold {
+					if original := p.params(r.URL.Path, true); original != nil {
+						params = original
+					}
+				}
+				p.handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), paramsKey{}, params)))
+			})}
+		}
+	}
+	return match{}
+}
+
+// ParamFromContext returns the value captured for the named segment
+// of the template registered with HandleParam, or the empty string.
+func ParamFromContext(ctx context.Context, name string) string {
+	params, _ := ctx.Value(paramsKey{}).(map[string]string)
+	return params[name]
+}
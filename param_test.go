@@ -0,0 +1,73 @@
+package methodmux_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/pierreprinetti/go-methodmux"
+)
+
+func TestHandleParam(t *testing.T) {
+	testCases := [...]struct {
+		method       string
+		path         string
+		expectedCode int
+		expectedBody string
+	}{
+		{"GET", "/items/42", 200, "item 42"},
+		{"GET", "/items/42/reviews/7", 200, "review 7 of 42"},
+		{"GET", "/items/all", 201, ""},
+		{"GET", "/items/", 404, ""},
+		{"GET", "/items/42/extra", 404, ""},
+		{"GET", "/users/42", 404, ""},
+		{"DELETE", "/items/42", 405, ""},
+	}
+
+	mux := New()
+	mux.HandleFunc("GET", "/items/all", func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(201) })
+	mux.HandleParam("GET", "/items/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("item " + ParamFromContext(r.Context(), "id")))
+	}))
+	mux.HandleParam("GET", "/items/{id}/reviews/{review}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("review " + ParamFromContext(r.Context(), "review") + " of " + ParamFromContext(r.Context(), "id")))
+	}))
+
+	for _, tc := range testCases {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest(tc.method, tc.path, nil))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+			if tc.expectedBody != "" {
+				if want, have := tc.expectedBody, rw.Body.String(); have != want {
+					t.Errorf("expected body %q, found %q", want, have)
+				}
+			}
+		})
+	}
+
+	t.Run("pattern", func(t *testing.T) {
+		if _, pattern := mux.Handler(httptest.NewRequest("GET", "/items/42", nil)); pattern != "/items/{id}" {
+			t.Errorf("expected pattern %q, found %q", "/items/{id}", pattern)
+		}
+	})
+
+	t.Run("case-insensitive path", func(t *testing.T) {
+		mux := New()
+		mux.CaseInsensitivePath = true
+		mux.HandleParam("GET", "/Items/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(ParamFromContext(r.Context(), "id")))
+		}))
+
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, httptest.NewRequest("GET", "/ITEMS/AbC", nil))
+		if want, have := 200, rw.Code; have != want {
+			t.Errorf("expected status code %d, found %d", want, have)
+		}
+		if want, have := "AbC", rw.Body.String(); have != want {
+			t.Errorf("expected body %q, found %q", want, have)
+		}
+	})
+}
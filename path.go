@@ -0,0 +1,108 @@
+package methodmux
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// patternPath returns the path portion of a pattern, stripping the host
+// if any.
+func patternPath(pattern string) string {
+	if i := strings.Index(pattern, "/"); i > 0 {
+		return pattern[i:]
+	}
+	return pattern
+}
+
+// cleanPath returns the canonical path for p, eliminating . and ..
+// elements. It mirrors the canonicalization performed by http.ServeMux.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+	np := path.Clean(p)
+	// path.Clean removes trailing slash except for root;
+	// put the trailing slash back if necessary.
+	if p[len(p)-1] == '/' && np != "/" {
+		// Fast path for common case of p being the string we want:
+		if len(p) == len(np)+1 && strings.HasPrefix(p, np) {
+			np = p
+		} else {
+			np += "/"
+		}
+	}
+	return np
+}
+
+// withPath returns a shallow copy of r with the given URL path.
+func withPath(r *http.Request, p string) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = p
+	r2.URL.RawPath = ""
+	return r2
+}
+
+// withoutFragment returns r, or a shallow copy of r whose URL path is
+// stripped of the fragment that a client or a proxy leaked into it.
+// Clients are meant to drop the fragment of a URL before sending it,
+// but a literal "#" in the request target ends up in r.URL.Path; an
+// encoded "%23" is part of the path and is kept.
+func withoutFragment(r *http.Request) *http.Request {
+	if r.URL.Fragment != "" {
+		if p, _, found := strings.Cut(r.URL.Path, "#"); found {
+			return withPath(r, p)
+		}
+	}
+	raw, _, found := strings.Cut(r.URL.RawPath, "#")
+	if !found {
+		return r
+	}
+	p, err := url.PathUnescape(raw)
+	if err != nil {
+		return r
+	}
+	return withPath(r, p)
+}
+
+// uncleanedRequest returns a shallow copy of r that http.ServeMux
+// matches without canonicalizing its path, as it does for CONNECT
+// requests. As http.ServeMux does not strip the port from the host of
+// CONNECT requests, it is stripped here.
+func uncleanedRequest(r *http.Request) *http.Request {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	r2 := withPath(r, r.URL.Path)
+	r2.Method = http.MethodConnect
+	r2.Host = host
+	r2.URL.Host = host
+	return r2
+}
+
+// lowerASCII returns s with the ASCII letters mapped to lower case,
+// leaving all the other bytes untouched.
+func lowerASCII(s string) string {
+	for i := 0; i < len(s); i++ {
+		if 'A' <= s[i] && s[i] <= 'Z' {
+			b := []byte(s)
+			for j := i; j < len(b); j++ {
+				if 'A' <= b[j] && b[j] <= 'Z' {
+					b[j] += 'a' - 'A'
+				}
+			}
+			return string(b)
+		}
+	}
+	return s
+}
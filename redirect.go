@@ -0,0 +1,39 @@
+package methodmux
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+)
+
+// redirectType is the type of the redirect handlers generated by
+// http.ServeMux.
+var redirectType = reflect.TypeOf(http.RedirectHandler("/", http.StatusMovedPermanently))
+
+// isRedirect reports whether h is a redirect handler generated by
+// http.ServeMux.
+func isRedirect(h http.Handler) bool {
+	return reflect.TypeOf(h) == redirectType
+}
+
+// redirectTarget returns the canonical path of r, that http.ServeMux
+// redirects to before matching pattern.
+func redirectTarget(r *http.Request, pattern string) string {
+	target := r.URL.Path
+	if r.Method != http.MethodConnect {
+		target = cleanPath(target)
+	}
+
+	if patternPath(pattern) == target+"/" {
+		target += "/"
+	}
+
+	return target
+}
+
+// redirectHandler returns a handler that redirects r with the given
+// code to the target path, carrying over the query string.
+func redirectHandler(r *http.Request, target string, code int) http.Handler {
+	u := &url.URL{Path: target, RawQuery: r.URL.RawQuery, Fragment: r.URL.Fragment}
+	return http.RedirectHandler(u.String(), code)
+}
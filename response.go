@@ -0,0 +1,184 @@
+package methodmux
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HandleCached registers the handler for the given method and pattern,
+// answering the conditional GET and HEAD requests. The ETag of each
+// request is computed by etagFn; it is quoted if needed and set as the
+// ETag header of the 2xx responses. If the request has a matching
+// If-None-Match header, the response is a 304 "Not Modified" and the
+// handler is not called. If etagFn returns the empty string, the
+// handler is called unconditionally. See HandleConditional for
+// If-Modified-Since.
+func (mux *ServeMux) HandleCached(method, pattern string, handler http.Handler, etagFn func(*http.Request) string) {
+	mux.HandleConditional(method, pattern, handler, etagFn, nil)
+}
+
+// HandleConditional is like HandleCached, and also answers the
+// requests with an If-Modified-Since header with a 304 "Not Modified"
+// when the modification time returned by lastModifiedFn is not later.
+// The modification time is set as the Last-Modified header of the 2xx
+// responses, with a precision of one second. As required by RFC 9110,
+// If-Modified-Since is ignored when the request has an If-None-Match
+// header. Either function can be nil, or return a zero value for
+// unknown.
+func (mux *ServeMux) HandleConditional(method, pattern string, handler http.Handler, etagFn func(*http.Request) string, lastModifiedFn func(*http.Request) time.Time) {
+	mux.Handle(method, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		vw := &validatorWriter{ResponseWriter: w}
+		if etagFn != nil {
+			if vw.etag = etagFn(r); vw.etag != "" && !strings.HasSuffix(vw.etag, `"`) {
+				vw.etag = `"` + vw.etag + `"`
+			}
+		}
+		if lastModifiedFn != nil {
+			vw.lastModified = lastModifiedFn(r).UTC().Truncate(time.Second)
+		}
+		if vw.etag == "" && vw.lastModified.IsZero() {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		if (r.Method == http.MethodGet || r.Method == http.MethodHead) && notModified(r, vw.etag, vw.lastModified) {
+			vw.WriteHeader(http.StatusNotModified)
+			return
+		}
+		handler.ServeHTTP(vw, r)
+	}))
+}
+
+// notModified reports whether the conditional headers of r match the
+// given validators, following the precedence of RFC 9110.
+func notModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return etag != "" && etagMatch(ifNoneMatch, etag)
+	}
+	if ifModifiedSince := r.Header.Get("If-Modified-Since"); ifModifiedSince != "" && !lastModified.IsZero() {
+		t, err := http.ParseTime(ifModifiedSince)
+		return err == nil && !lastModified.After(t)
+	}
+	return false
+}
+
+// HandleStaticBytes registers, for the given method and pattern, a
+// handler that serves data with the given Content-Type, such as a
+// favicon or a robots.txt file. The ETag of the response is derived
+// from data, and conditional requests are answered like with
+// HandleCached. data is copied on registration.
+func (mux *ServeMux) HandleStaticBytes(method, pattern string, contentType string, data []byte) {
+	data = append([]byte(nil), data...)
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	contentLength := strconv.Itoa(len(data))
+
+	mux.HandleCached(method, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", contentLength)
+		if r.Method != http.MethodHead {
+			w.Write(data)
+		}
+	}), func(*http.Request) string { return etag })
+}
+
+// etagMatch reports whether the value of an If-None-Match header
+// matches etag, using the weak comparison.
+func etagMatch(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleErr registers the error-returning handler function for the
+// given method and pattern. If the handler returns an error, it is
+// rendered with ErrorHandler.
+func (mux *ServeMux) HandleErr(method, pattern string, handler func(http.ResponseWriter, *http.Request) error) {
+	mux.Handle(method, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := handler(w, r); err != nil {
+			if mux.ErrorHandler != nil {
+				mux.ErrorHandler(w, r, err)
+				return
+			}
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	}))
+}
+
+// HandleWithErrorPages registers the handler for the given method and
+// pattern, substituting the responses that have a status code in pages
+// and an empty body with the response of the corresponding page. The
+// page keeps the original status code, whatever status it writes.
+//
+// Once the handler has written or flushed the body, the response is
+// streamed to the client and left untouched.
+func (mux *ServeMux) HandleWithErrorPages(method, pattern string, handler http.Handler, pages map[int]http.Handler) {
+	mux.Handle(method, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ew := &errorPageWriter{ResponseWriter: w, pages: pages}
+		handler.ServeHTTP(ew, r)
+
+		if code := ew.pending; code != 0 {
+			w.Header().Del("Content-Length")
+			cw := &codeWriter{ResponseWriter: w, code: code}
+			pages[code].ServeHTTP(cw, r)
+			cw.WriteHeader(code)
+		}
+	}))
+}
+
+// HandleCompressed registers the handler for the given method and
+// pattern, compressing the responses with gzip for the clients that
+// accept it. Responses smaller than 1 KiB, responses that have a
+// Content-Encoding and responses whose content type is already
+// compressed, such as images and archives, are sent as is.
+func (mux *ServeMux) HandleCompressed(method, pattern string, handler http.Handler) {
+	mux.Handle(method, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		addVary(w.Header(), "Accept-Encoding")
+		if !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipWriter{ResponseWriter: w}
+		defer gw.close()
+		handler.ServeHTTP(gw, r)
+	}))
+}
+
+// acceptsGzip reports whether the value of an Accept-Encoding header
+// accepts the gzip encoding.
+func acceptsGzip(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.TrimSpace(coding)
+		if !strings.EqualFold(coding, "gzip") && coding != "*" {
+			continue
+		}
+		q := strings.ReplaceAll(params, " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
+
+// HandleHeaders registers the handler for the given method and pattern,
+// setting the given response headers before calling the handler, which
+// can override them. headers is copied on registration.
+func (mux *ServeMux) HandleHeaders(method, pattern string, headers http.Header, handler http.Handler) {
+	headers = headers.Clone()
+
+	mux.Handle(method, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		for key, values := range headers {
+			h[key] = append([]string(nil), values...)
+		}
+		handler.ServeHTTP(w, r)
+	}))
+}
@@ -0,0 +1,391 @@
+package methodmux_test
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/pierreprinetti/go-methodmux"
+)
+
+func TestHandleCached(t *testing.T) {
+	testCases := [...]struct {
+		name         string
+		method       string
+		ifNoneMatch  string
+		expectedCode int
+		expectedBody string
+	}{
+		{"miss", "GET", "", 200, "payload"},
+		{"stale", "GET", `"v1"`, 200, "payload"},
+		{"hit", "GET", `"v2"`, 304, ""},
+		{"weak hit", "GET", `W/"v2"`, 304, ""},
+		{"hit in list", "GET", `"v1", "v2"`, 304, ""},
+		{"wildcard", "HEAD", "*", 304, ""},
+	}
+
+	mux := New()
+	mux.HandleCached("GET", "/data", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("payload"))
+	}), func(*http.Request) string { return "v2" })
+	mux.HandleCached("HEAD", "/data", serve(200), func(*http.Request) string { return `"v2"` })
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/data", nil)
+			if tc.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tc.ifNoneMatch)
+			}
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, req)
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+			if want, have := tc.expectedBody, rw.Body.String(); have != want {
+				t.Errorf("expected body %q, found %q", want, have)
+			}
+			if want, have := `"v2"`, rw.Header().Get("ETag"); have != want {
+				t.Errorf("expected ETag %q, found %q", want, have)
+			}
+		})
+	}
+
+	t.Run("error response", func(t *testing.T) {
+		mux := New()
+		mux.HandleCached("GET", "/data", serve(404), func(*http.Request) string { return "v2" })
+
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, httptest.NewRequest("GET", "/data", nil))
+		if want, have := 404, rw.Code; have != want {
+			t.Errorf("expected status code %d, found %d", want, have)
+		}
+		if etag := rw.Header().Get("ETag"); etag != "" {
+			t.Errorf("expected no ETag, found %q", etag)
+		}
+	})
+}
+
+func TestHandleConditional(t *testing.T) {
+	modified := time.Date(2026, 3, 1, 12, 0, 0, 500, time.UTC)
+
+	testCases := [...]struct {
+		name            string
+		ifNoneMatch     string
+		ifModifiedSince string
+		expectedCode    int
+	}{
+		{"unconditional", "", "", 200},
+		{"modified since", "", "Sun, 01 Mar 2026 11:59:59 GMT", 200},
+		{"not modified since", "", "Sun, 01 Mar 2026 12:00:00 GMT", 304},
+		{"not modified since, later", "", "Mon, 02 Mar 2026 00:00:00 GMT", 304},
+		{"invalid date", "", "yesterday", 200},
+		{"If-None-Match takes precedence on a miss", `"v1"`, "Mon, 02 Mar 2026 00:00:00 GMT", 200},
+		{"If-None-Match takes precedence on a hit", `"v2"`, "Sun, 01 Mar 2026 11:59:59 GMT", 304},
+	}
+
+	mux := New()
+	mux.HandleConditional("GET", "/data", serve(200), func(*http.Request) string { return "v2" }, func(*http.Request) time.Time { return modified })
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/data", nil)
+			if tc.ifNoneMatch != "" {
+				req.Header.Set("If-None-Match", tc.ifNoneMatch)
+			}
+			if tc.ifModifiedSince != "" {
+				req.Header.Set("If-Modified-Since", tc.ifModifiedSince)
+			}
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, req)
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+			if want, have := "Sun, 01 Mar 2026 12:00:00 GMT", rw.Header().Get("Last-Modified"); have != want {
+				t.Errorf("expected Last-Modified %q, found %q", want, have)
+			}
+		})
+	}
+}
+
+func TestHandleStaticBytes(t *testing.T) {
+	data := []byte("User-agent: *\nDisallow:\n")
+
+	mux := New()
+	mux.HandleStaticBytes("GET", "/robots.txt", "text/plain; charset=utf-8", data)
+	data[0] = 'u'
+
+	rw := httptest.NewRecorder()
+	mux.ServeHTTP(rw, httptest.NewRequest("GET", "/robots.txt", nil))
+	if want, have := 200, rw.Code; have != want {
+		t.Errorf("expected status code %d, found %d", want, have)
+	}
+	if want, have := "User-agent: *\nDisallow:\n", rw.Body.String(); have != want {
+		t.Errorf("expected body %q, found %q", want, have)
+	}
+	if want, have := "text/plain; charset=utf-8", rw.Header().Get("Content-Type"); have != want {
+		t.Errorf("expected Content-Type %q, found %q", want, have)
+	}
+	if want, have := "24", rw.Header().Get("Content-Length"); have != want {
+		t.Errorf("expected Content-Length %q, found %q", want, have)
+	}
+
+	etag := rw.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("expected an ETag")
+	}
+
+	req := httptest.NewRequest("GET", "/robots.txt", nil)
+	req.Header.Set("If-None-Match", etag)
+	rw = httptest.NewRecorder()
+	mux.ServeHTTP(rw, req)
+	if want, have := 304, rw.Code; have != want {
+		t.Errorf("expected status code %d, found %d", want, have)
+	}
+	if rw.Body.Len() != 0 {
+		t.Errorf("expected an empty body, found %q", rw.Body.String())
+	}
+}
+
+func TestHandleErr(t *testing.T) {
+	errNotFound := errors.New("no such item")
+
+	handler := func(w http.ResponseWriter, r *http.Request) error {
+		switch r.URL.Query().Get("outcome") {
+		case "missing":
+			return errNotFound
+		case "failure":
+			return errors.New("database unreachable")
+		}
+		w.WriteHeader(200)
+		return nil
+	}
+
+	testCases := [...]struct {
+		name         string
+		errorHandler func(http.ResponseWriter, *http.Request, error)
+		outcome      string
+		expectedCode int
+	}{
+		{"success", nil, "", 200},
+		{"default error handler", nil, "missing", 500},
+		{"custom error handler", func(w http.ResponseWriter, r *http.Request, err error) {
+			if errors.Is(err, errNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}, "missing", 404},
+		{"custom error handler fallback", func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}, "failure", 502},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mux := New()
+			mux.ErrorHandler = tc.errorHandler
+			mux.HandleErr("GET", "/items", handler)
+
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest("GET", "/items?outcome="+tc.outcome, nil))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+}
+
+func TestHandleWithErrorPages(t *testing.T) {
+	pages := map[int]http.Handler{
+		404: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<h1>Not here</h1>"))
+		}),
+		500: http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(200)
+		}),
+	}
+
+	testCases := [...]struct {
+		path         string
+		expectedCode int
+		expectedBody string
+	}{
+		{"/missing", 404, "<h1>Not here</h1>"},
+		{"/missing-with-body", 404, "gone\n"},
+		{"/failure", 500, ""},
+		{"/conflict", 409, ""},
+		{"/ok", 200, "fine"},
+		{"/streamed", 404, ""},
+	}
+
+	mux := New()
+	mux.HandleWithErrorPages("GET", "/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(404)
+		case "/missing-with-body":
+			w.WriteHeader(404)
+			w.Write([]byte("gone\n"))
+		case "/failure":
+			w.WriteHeader(500)
+		case "/conflict":
+			w.WriteHeader(409)
+		case "/ok":
+			w.Write([]byte("fine"))
+		case "/streamed":
+			w.WriteHeader(404)
+			w.(http.Flusher).Flush()
+		}
+	}), pages)
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest("GET", tc.path, nil))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+			if want, have := tc.expectedBody, rw.Body.String(); have != want {
+				t.Errorf("expected body %q, found %q", want, have)
+			}
+		})
+	}
+}
+
+func TestHandleCompressed(t *testing.T) {
+	large := strings.Repeat(`{"item":"value"}`, 200)
+
+	testCases := [...]struct {
+		name             string
+		acceptEncoding   string
+		contentType      string
+		body             string
+		expectedEncoding string
+	}{
+		{"accepted", "gzip, deflate", "application/json", large, "gzip"},
+		{"not accepted", "deflate", "application/json", large, ""},
+		{"refused", "gzip;q=0", "application/json", large, ""},
+		{"small", "gzip", "application/json", `{"item":"value"}`, ""},
+		{"already compressed", "gzip", "image/png", large, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mux := New()
+			mux.HandleCompressed("GET", "/items", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", tc.contentType)
+				w.Write([]byte(tc.body))
+			}))
+
+			req := httptest.NewRequest("GET", "/items", nil)
+			req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, req)
+
+			if want, have := tc.expectedEncoding, rw.Header().Get("Content-Encoding"); have != want {
+				t.Fatalf("expected Content-Encoding %q, found %q", want, have)
+			}
+			if want, have := "Accept-Encoding", rw.Header().Get("Vary"); have != want {
+				t.Errorf("expected Vary %q, found %q", want, have)
+			}
+
+			body := io.Reader(rw.Body)
+			if tc.expectedEncoding == "gzip" {
+				zr, err := gzip.NewReader(rw.Body)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				body = zr
+			}
+			b, err := io.ReadAll(body)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want, have := tc.body, string(b); have != want {
+				t.Errorf("expected body of %d bytes, found %d bytes", len(want), len(have))
+			}
+		})
+	}
+
+	t.Run("flush", func(t *testing.T) {
+		mux := New()
+		mux.HandleCompressed("GET", "/events", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.Write([]byte("data: 1\n\n"))
+			w.(http.Flusher).Flush()
+			w.Write([]byte("data: 2\n\n"))
+		}))
+
+		req := httptest.NewRequest("GET", "/events", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, req)
+
+		if !rw.Flushed {
+			t.Errorf("expected the response to be flushed")
+		}
+		zr, err := gzip.NewReader(rw.Body)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		b, _ := io.ReadAll(zr)
+		if want, have := "data: 1\n\ndata: 2\n\n", string(b); have != want {
+			t.Errorf("expected body %q, found %q", want, have)
+		}
+	})
+
+	t.Run("Vary set already", func(t *testing.T) {
+		mux := New()
+		mux.HandleCompressed("GET", "/items", serve(200))
+
+		rw := httptest.NewRecorder()
+		rw.Header().Set("Vary", "Accept-Encoding")
+		mux.ServeHTTP(rw, httptest.NewRequest("GET", "/items", nil))
+		if want, have := []string{"Accept-Encoding"}, rw.Header().Values("Vary"); !reflect.DeepEqual(have, want) {
+			t.Errorf("expected Vary %q, found %q", want, have)
+		}
+	})
+}
+
+func TestHandleHeaders(t *testing.T) {
+	headers := http.Header{
+		"Cache-Control":   {"no-store"},
+		"X-Frame-Options": {"DENY"},
+	}
+
+	mux := New()
+	mux.HandleHeaders("GET", "/default", headers, serve(200))
+	mux.HandleHeaders("GET", "/override", headers, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.WriteHeader(200)
+	}))
+	headers.Set("X-Frame-Options", "SAMEORIGIN")
+
+	testCases := [...]struct {
+		path                 string
+		expectedCacheControl string
+	}{
+		{"/default", "no-store"},
+		{"/override", "max-age=60"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest("GET", tc.path, nil))
+			if want, have := tc.expectedCacheControl, rw.Result().Header.Get("Cache-Control"); have != want {
+				t.Errorf("expected Cache-Control %q, found %q", want, have)
+			}
+			if want, have := "DENY", rw.Result().Header.Get("X-Frame-Options"); have != want {
+				t.Errorf("expected X-Frame-Options %q, found %q", want, have)
+			}
+		})
+	}
+}
@@ -0,0 +1,477 @@
+package methodmux
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ErrPatternConflict is returned when registering a handler for a
+// combination of method and pattern that is already registered.
+var ErrPatternConflict = errors.New("methodmux: pattern conflict")
+
+// Route is a handler registered for a method and a pattern. Its JSON
+// encoding only holds the method and the pattern.
+type Route struct {
+	Method  string       `json:"method"`
+	Pattern string       `json:"pattern"`
+	Handler http.Handler `json:"-"`
+}
+
+// route is the http.Handler registered to the underlying http.ServeMux.
+// It tells the registered handlers apart from the redirect handlers
+// that http.ServeMux generates.
+type route struct {
+	handler  http.Handler // nil if only conditional handlers are registered
+	conds    []conditional
+	hits     atomic.Uint64
+	draining atomic.Bool
+	disabled atomic.Bool
+}
+
+// conditional is a handler that only serves the requests it matches.
+type conditional struct {
+	match   func(*http.Request) bool
+	handler http.Handler
+}
+
+// handlerFor returns the first conditional handler matching r, or the
+// unconditional handler. It returns nil if no handler applies.
+func (rt *route) handlerFor(r *http.Request) http.Handler {
+	for _, c := range rt.conds {
+		if c.match(r) {
+			return c.handler
+		}
+	}
+	return rt.handler
+}
+
+func (rt *route) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h := rt.handlerFor(r); h != nil {
+		h.ServeHTTP(w, r)
+		return
+	}
+	NotFoundHandler.ServeHTTP(w, r)
+}
+
+// Routes returns the handlers registered with Handle, sorted by method
+// and then by pattern. Handlers registered with HandlePort are not
+// included, while those registered with HandleMethodPrefix are reported
+// with their prefix followed by "*" as method, such as "PROP*".
+// Patterns that only have conditional handlers, such as those
+// registered with HandleQuery, are reported with a nil Handler.
+func (mux *ServeMux) Routes() []Route {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+
+	var routes []Route
+	for method, patterns := range mux.routes {
+		for pattern, rt := range patterns {
+			routes = append(routes, Route{Method: method, Pattern: pattern, Handler: rt.handler})
+		}
+	}
+	for _, e := range mux.methodPrefixes {
+		for pattern, rt := range e.routes {
+			routes = append(routes, Route{Method: e.prefix + "*", Pattern: pattern, Handler: rt.handler})
+		}
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Method != routes[j].Method {
+			return routes[i].Method < routes[j].Method
+		}
+		return routes[i].Pattern < routes[j].Pattern
+	})
+
+	return routes
+}
+
+// MarshalJSON encodes the route table of mux, as reported by Routes, as
+// a JSON array of objects with the "method" and "pattern" members. The
+// handlers are not encoded.
+func (mux *ServeMux) MarshalJSON() ([]byte, error) {
+	routes := mux.Routes()
+	if routes == nil {
+		routes = []Route{}
+	}
+	return json.Marshal(routes)
+}
+
+// Len returns the number of combinations of method and pattern
+// registered with Handle and HandleMethodPrefix. It equals len(mux.Routes()), without building
+// the list of routes.
+func (mux *ServeMux) Len() int {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+
+	var n int
+	for _, patterns := range mux.routes {
+		n += len(patterns)
+	}
+	for _, e := range mux.methodPrefixes {
+		n += len(e.routes)
+	}
+	return n
+}
+
+// RoutesWithPrefix returns the routes whose pattern has a path portion
+// starting with prefix, in the same order as Routes. The host of
+// host-qualified patterns is not considered.
+func (mux *ServeMux) RoutesWithPrefix(prefix string) []Route {
+	var routes []Route
+	for _, route := range mux.Routes() {
+		if strings.HasPrefix(patternPath(route.Pattern), prefix) {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// OpenAPIPaths returns the registered patterns, each with the sorted
+// list of methods it has been registered for. The result maps directly
+// to the "paths" object of an OpenAPI document; wildcard segments such
+// as "{id}" are left untouched, as they already follow the OpenAPI
+// path parameter syntax. The handlers registered with
+// HandleMethodPrefix, which have no single method, are left out.
+func (mux *ServeMux) OpenAPIPaths() map[string][]string {
+	paths := make(map[string][]string)
+	for _, route := range mux.Routes() {
+		if strings.HasSuffix(route.Method, "*") {
+			continue
+		}
+		paths[route.Pattern] = append(paths[route.Pattern], route.Method)
+	}
+	return paths
+}
+
+// Methods returns the methods that handlers have been registered for
+// with Handle, in the order they were first registered.
+func (mux *ServeMux) Methods() []string {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+
+	return append([]string(nil), mux.methods...)
+}
+
+// HasMethod reports whether any handler has been registered with Handle
+// for the given method.
+func (mux *ServeMux) HasMethod(method string) bool {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+
+	return len(mux.routes[method]) > 0
+}
+
+// Swap registers the handler for the given method and pattern, like
+// Handle, but replaces the existing handler instead of panicking if the
+// combination of method and pattern is already registered. It returns
+// the replaced handler, and whether one existed. Conditional handlers
+// are left untouched.
+func (mux *ServeMux) Swap(method, pattern string, handler http.Handler) (old http.Handler, existed bool) {
+	method, pattern = mux.onRegister(method, pattern)
+
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	if handler == nil {
+		panic("methodmux: nil handler")
+	}
+
+	rt := mux.routeFor(method, pattern)
+	old, rt.handler = rt.handler, handler
+	return old, old != nil
+}
+
+// DuplicatePolicy tells HandlePolicy what to do when a handler is
+// already registered for the method and the pattern.
+type DuplicatePolicy int
+
+const (
+	// PanicOnDup makes HandlePolicy panic, like Handle.
+	PanicOnDup DuplicatePolicy = iota
+
+	// Overwrite makes HandlePolicy replace the registered handler,
+	// like Swap.
+	Overwrite
+
+	// Ignore makes HandlePolicy keep the registered handler, dropping
+	// the new one.
+	Ignore
+)
+
+// HandlePolicy registers the handler for the given method and pattern,
+// like Handle, applying policy if a handler is already registered for
+// them. Conditional handlers are left untouched.
+func (mux *ServeMux) HandlePolicy(method, pattern string, handler http.Handler, policy DuplicatePolicy) {
+	switch policy {
+	case Overwrite:
+		mux.Swap(method, pattern, handler)
+	case Ignore:
+		method, pattern = mux.onRegister(method, pattern)
+
+		mux.mu.Lock()
+		defer mux.mu.Unlock()
+
+		if handler == nil {
+			panic("methodmux: nil handler")
+		}
+		if rt := mux.routeFor(method, pattern); rt.handler == nil {
+			rt.handler = handler
+		}
+	default:
+		mux.Handle(method, pattern, handler)
+	}
+}
+
+// HandleBatch registers the handlers of all the given routes, or none of
+// them. If any route conflicts with a registered handler or with another
+// route of the batch, or is otherwise invalid, HandleBatch returns a
+// descriptive error and leaves mux untouched. Conflicts are reported
+// with an error wrapping ErrPatternConflict.
+func (mux *ServeMux) HandleBatch(routes []Route) error {
+	if mux.OnRegister != nil {
+		registered := make([]Route, len(routes))
+		for i, route := range routes {
+			method, pattern, err := mux.OnRegister(route.Method, route.Pattern)
+			if err != nil {
+				return fmt.Errorf("methodmux: %s %s: %w", route.Method, route.Pattern, err)
+			}
+			registered[i] = Route{Method: method, Pattern: pattern, Handler: route.Handler}
+		}
+		routes = registered
+	}
+
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	if err := mux.validate(routes); err != nil {
+		return err
+	}
+
+	for _, route := range routes {
+		mux.handle(route.Method, route.Pattern, route.Handler)
+	}
+	return nil
+}
+
+// validate returns an error if registering routes would panic. The
+// caller must hold the read lock.
+func (mux *ServeMux) validate(routes []Route) error {
+	trial := make(map[string]*http.ServeMux)
+	for _, route := range routes {
+		if _, exists := trial[route.Method]; exists {
+			continue
+		}
+		trial[route.Method] = http.NewServeMux()
+		for pattern := range mux.routes[route.Method] {
+			trial[route.Method].Handle(pattern, NotFoundHandler)
+		}
+	}
+
+	seen := make(map[[2]string]bool)
+	for _, route := range routes {
+		route.Pattern = mux.foldPattern(route.Pattern)
+		key := [2]string{route.Method, route.Pattern}
+		if seen[key] {
+			return fmt.Errorf("%w: %s %s appears more than once", ErrPatternConflict, route.Method, route.Pattern)
+		}
+		seen[key] = true
+		if route.Handler == nil {
+			return fmt.Errorf("methodmux: %s %s: nil handler", route.Method, route.Pattern)
+		}
+		if err := mux.checkPattern(route.Pattern); err != nil {
+			return err
+		}
+		if rt, exists := mux.routes[route.Method][route.Pattern]; exists {
+			if rt.handler != nil {
+				return fmt.Errorf("%w: %s %s is already registered", ErrPatternConflict, route.Method, route.Pattern)
+			}
+			continue
+		}
+		if err := tryHandle(trial[route.Method], route); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tryHandle registers route to m, turning a panic into an error.
+func tryHandle(m *http.ServeMux, route Route) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("methodmux: %s %s: %v", route.Method, route.Pattern, r)
+		}
+	}()
+
+	m.Handle(route.Pattern, route.Handler)
+	return nil
+}
+
+// DrainRoute marks the route registered with Handle for the given
+// method and pattern as draining: new requests matching it are answered
+// with 503 "Service Unavailable", while the requests already being
+// served are left to complete. It has no effect if no such route
+// exists.
+func (mux *ServeMux) DrainRoute(method, pattern string) {
+	mux.setDraining(method, pattern, true)
+}
+
+// UndrainRoute reverts DrainRoute, making the route serve new requests
+// again.
+func (mux *ServeMux) UndrainRoute(method, pattern string) {
+	mux.setDraining(method, pattern, false)
+}
+
+func (mux *ServeMux) setDraining(method, pattern string, draining bool) {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+
+	if rt, exists := mux.routes[method][mux.foldPattern(pattern)]; exists {
+		rt.draining.Store(draining)
+	}
+}
+
+// SetRouteEnabled enables or disables the route registered with Handle
+// for the given method and pattern. A disabled route stays registered,
+// but requests are answered as if it was not: like after Deregister,
+// they fall through to the remaining patterns, or to a 405 or a 404.
+// Routes are enabled when registered. SetRouteEnabled has no effect if
+// no such route exists.
+func (mux *ServeMux) SetRouteEnabled(method, pattern string, enabled bool) {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+
+	if rt, exists := mux.routes[method][mux.foldPattern(pattern)]; exists {
+		rt.disabled.Store(!enabled)
+	}
+}
+
+// Deregister removes the route registered with Handle for the given
+// method and pattern, along with its conditional handlers, and reports
+// whether it existed. Requests that the route matched fall through to
+// the remaining patterns, or to a 405 or a 404. The underlying
+// http.ServeMux of method is replaced, dropping any handler registered
+// to it directly; see MethodMux.
+func (mux *ServeMux) Deregister(method, pattern string) bool {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	rt, exists := mux.routes[method][mux.foldPattern(pattern)]
+	if exists {
+		mux.deregister(method, mux.foldPattern(pattern), rt)
+	}
+	return exists
+}
+
+// deregister removes rt, registered for the given method and folded
+// pattern, rebuilding the underlying http.ServeMux of method, which
+// does not support removals. The caller must hold the write lock.
+func (mux *ServeMux) deregister(method, pattern string, rt *route) {
+	delete(mux.routes[method], pattern)
+	mux.index.Store(nil)
+
+	if len(mux.routes[method]) == 0 {
+		delete(mux.routes, method)
+		delete(mux.m, method)
+		for i, m := range mux.methods {
+			if m == method {
+				mux.methods = append(mux.methods[:i:i], mux.methods[i+1:]...)
+				break
+			}
+		}
+		return
+	}
+
+	m := http.NewServeMux()
+	for pattern, rt := range mux.routes[method] {
+		m.Handle(pattern, rt)
+	}
+	mux.m[method] = m
+}
+
+// HandleCancelable registers the handler for the given method and
+// pattern, like Handle, and returns a function that deregisters it. The
+// returned function does nothing if the route has already been
+// deregistered, even if the same method and pattern have been
+// registered again since.
+func (mux *ServeMux) HandleCancelable(method, pattern string, handler http.Handler) (cancel func()) {
+	method, pattern = mux.onRegister(method, pattern)
+
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	mux.handle(method, pattern, handler)
+	pattern = mux.foldPattern(pattern)
+	rt := mux.routes[method][pattern]
+
+	return func() {
+		mux.mu.Lock()
+		defer mux.mu.Unlock()
+
+		if mux.routes[method][pattern] == rt {
+			mux.deregister(method, pattern, rt)
+		}
+	}
+}
+
+// HandleTTL registers the handler for the given method and pattern,
+// like Handle, and deregisters it once ttl has elapsed. The returned
+// function deregisters it right away and stops the timer; see
+// HandleCancelable.
+func (mux *ServeMux) HandleTTL(method, pattern string, handler http.Handler, ttl time.Duration) (cancel func()) {
+	deregister := mux.HandleCancelable(method, pattern, handler)
+	timer := time.AfterFunc(ttl, deregister)
+
+	return func() {
+		timer.Stop()
+		deregister()
+	}
+}
+
+// HandleWindow registers the handler for the given method and pattern,
+// only serving the requests received within the time window from
+// start, included, to end, excluded. Outside of the window, requests
+// are answered by WindowClosedHandler, or with 404 "Not Found" if it is
+// not set, while the route stays registered.
+func (mux *ServeMux) HandleWindow(method, pattern string, start, end time.Time, handler http.Handler) {
+	mux.Handle(method, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if now := mux.now(); now.Before(start) || !now.Before(end) {
+			closed := mux.WindowClosedHandler
+			if closed == nil {
+				closed = NotFoundHandler
+			}
+			closed.ServeHTTP(w, r)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+}
+
+// now returns the current time, as reported by Now if set.
+func (mux *ServeMux) now() time.Time {
+	if mux.Now != nil {
+		return mux.Now()
+	}
+	return time.Now()
+}
+
+// SameRoutes reports whether mux and other have handlers registered for
+// the same combinations of method and pattern, regardless of the
+// handlers themselves.
+func (mux *ServeMux) SameRoutes(other *ServeMux) bool {
+	a, b := mux.Routes(), other.Routes()
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Method != b[i].Method || a[i].Pattern != b[i].Pattern {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,565 @@
+package methodmux_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	. "github.com/pierreprinetti/go-methodmux"
+)
+
+func TestRoutes(t *testing.T) {
+	t.Run("lists the registered routes", func(t *testing.T) {
+		mux := New()
+		mux.Handle("POST", "/items/", serve(201))
+		mux.Handle("GET", "/items/", serve(200))
+		mux.Handle("GET", "/", serve(200))
+
+		var have [][2]string
+		for _, route := range mux.Routes() {
+			have = append(have, [2]string{route.Method, route.Pattern})
+		}
+		want := [][2]string{
+			{"GET", "/"},
+			{"GET", "/items/"},
+			{"POST", "/items/"},
+		}
+		if !reflect.DeepEqual(have, want) {
+			t.Errorf("expected routes %v, found %v", want, have)
+		}
+	})
+}
+
+func TestOpenAPIPaths(t *testing.T) {
+	t.Run("groups methods by pattern", func(t *testing.T) {
+		mux := New()
+		mux.Handle("POST", "/items/", serve(201))
+		mux.Handle("GET", "/items/", serve(200))
+		mux.Handle("DELETE", "/items/{id}", serve(204))
+
+		want := map[string][]string{
+			"/items/":     {"GET", "POST"},
+			"/items/{id}": {"DELETE"},
+		}
+		if have := mux.OpenAPIPaths(); !reflect.DeepEqual(have, want) {
+			t.Errorf("expected paths %v, found %v", want, have)
+		}
+	})
+}
+
+func TestHasMethod(t *testing.T) {
+	mux := New()
+	mux.Handle("GET", "/", serve(200))
+
+	if !mux.HasMethod("GET") {
+		t.Errorf("expected HasMethod(%q) to be true", "GET")
+	}
+	if mux.HasMethod("DELETE") {
+		t.Errorf("expected HasMethod(%q) to be false", "DELETE")
+	}
+}
+
+func TestSwap(t *testing.T) {
+	t.Run("replaces an existing handler", func(t *testing.T) {
+		original := serve(200)
+		mux := New()
+		mux.Handle("GET", "/some/path", original)
+
+		old, existed := mux.Swap("GET", "/some/path", serve(201))
+		if !existed {
+			t.Errorf("expected the route to exist")
+		}
+		if reflect.ValueOf(old).Pointer() != reflect.ValueOf(original).Pointer() {
+			t.Errorf("expected the original handler to be returned")
+		}
+
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, httptest.NewRequest("GET", "/some/path", nil))
+		if want, have := 201, rw.Code; have != want {
+			t.Errorf("expected status code %d, found %d", want, have)
+		}
+	})
+
+	t.Run("registers a new handler", func(t *testing.T) {
+		mux := New()
+
+		old, existed := mux.Swap("GET", "/some/path", serve(201))
+		if existed || old != nil {
+			t.Errorf("expected no previous handler, found %v", old)
+		}
+
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, httptest.NewRequest("GET", "/some/path", nil))
+		if want, have := 201, rw.Code; have != want {
+			t.Errorf("expected status code %d, found %d", want, have)
+		}
+	})
+}
+
+func TestMethods(t *testing.T) {
+	t.Run("returns methods in registration order", func(t *testing.T) {
+		want := []string{"PATCH", "GET", "DELETE", "POST", "MYMETHOD"}
+
+		mux := New()
+		for _, method := range want {
+			mux.Handle(method, "/a", serve(200))
+			mux.Handle(method, "/b", serve(200))
+		}
+
+		for i := 0; i < 100; i++ {
+			if have := mux.Methods(); !reflect.DeepEqual(have, want) {
+				t.Fatalf("expected methods %v, found %v", want, have)
+			}
+		}
+	})
+}
+
+func TestHandleBatch(t *testing.T) {
+	t.Run("registers a clean batch", func(t *testing.T) {
+		mux := New()
+		mux.Handle("GET", "/", serve(200))
+
+		err := mux.HandleBatch([]Route{
+			{Method: "GET", Pattern: "/items/", Handler: serve(201)},
+			{Method: "POST", Pattern: "/items/", Handler: serve(202)},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want, have := 3, len(mux.Routes()); have != want {
+			t.Errorf("expected %d routes, found %d", want, have)
+		}
+	})
+
+	testCases := [...]struct {
+		name     string
+		routes   []Route
+		conflict bool
+	}{
+		{"conflict with the mux", []Route{
+			{Method: "POST", Pattern: "/items/", Handler: serve(201)},
+			{Method: "GET", Pattern: "/", Handler: serve(202)},
+		}, true},
+		{"conflict within the batch", []Route{
+			{Method: "POST", Pattern: "/items/", Handler: serve(201)},
+			{Method: "POST", Pattern: "/items/", Handler: serve(202)},
+		}, true},
+		{"nil handler", []Route{
+			{Method: "POST", Pattern: "/items/", Handler: serve(201)},
+			{Method: "PUT", Pattern: "/items/"},
+		}, false},
+		{"invalid pattern", []Route{
+			{Method: "POST", Pattern: "/items/", Handler: serve(201)},
+			{Method: "PUT", Pattern: "", Handler: serve(202)},
+		}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mux := New()
+			mux.Handle("GET", "/", serve(200))
+
+			err := mux.HandleBatch(tc.routes)
+			if err == nil {
+				t.Fatalf("expected an error")
+			}
+			if have, want := errors.Is(err, ErrPatternConflict), tc.conflict; have != want {
+				t.Errorf("expected errors.Is(err, ErrPatternConflict) to be %t, found %t (%v)", want, have, err)
+			}
+			if want, have := 1, len(mux.Routes()); have != want {
+				t.Errorf("expected %d route, found %d", want, have)
+			}
+		})
+	}
+}
+
+func TestRoutesWithPrefix(t *testing.T) {
+	mux := New()
+	mux.Handle("GET", "/api/items", serve(200))
+	mux.Handle("POST", "/api/items", serve(201))
+	mux.Handle("GET", "api.example.com/api/users", serve(200))
+	mux.Handle("GET", "/apiary", serve(200))
+	mux.Handle("GET", "/", serve(200))
+
+	var have [][2]string
+	for _, route := range mux.RoutesWithPrefix("/api/") {
+		have = append(have, [2]string{route.Method, route.Pattern})
+	}
+	want := [][2]string{
+		{"GET", "/api/items"},
+		{"GET", "api.example.com/api/users"},
+		{"POST", "/api/items"},
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("expected routes %v, found %v", want, have)
+	}
+}
+
+func TestSameRoutes(t *testing.T) {
+	build := func(routes ...[2]string) *ServeMux {
+		mux := New()
+		for _, route := range routes {
+			mux.Handle(route[0], route[1], serve(200))
+		}
+		return mux
+	}
+
+	testCases := [...]struct {
+		name     string
+		a, b     *ServeMux
+		expected bool
+	}{
+		{"empty", New(), New(), true},
+		{"identical",
+			build([2]string{"GET", "/a"}, [2]string{"POST", "/b"}),
+			build([2]string{"POST", "/b"}, [2]string{"GET", "/a"}),
+			true},
+		{"different method",
+			build([2]string{"GET", "/a"}),
+			build([2]string{"POST", "/a"}),
+			false},
+		{"different pattern",
+			build([2]string{"GET", "/a"}),
+			build([2]string{"GET", "/a/"}),
+			false},
+		{"extra route",
+			build([2]string{"GET", "/a"}),
+			build([2]string{"GET", "/a"}, [2]string{"GET", "/b"}),
+			false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if have, want := tc.a.SameRoutes(tc.b), tc.expected; have != want {
+				t.Errorf("expected SameRoutes to be %t, found %t", want, have)
+			}
+		})
+	}
+}
+
+func TestDrainRoute(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	mux := New()
+	mux.Handle("GET", "/poll", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("wait") != "" {
+			close(started)
+			<-release
+		}
+		w.WriteHeader(200)
+	}))
+	mux.Handle("GET", "/other", serve(200))
+
+	expect := func(t *testing.T, path string, want int) {
+		t.Helper()
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, httptest.NewRequest("GET", path, nil))
+		if have := rw.Code; have != want {
+			t.Errorf("GET %s: expected status code %d, found %d", path, want, have)
+		}
+	}
+
+	inFlight := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		mux.ServeHTTP(inFlight, httptest.NewRequest("GET", "/poll?wait=1", nil))
+		close(done)
+	}()
+	<-started
+
+	mux.DrainRoute("GET", "/poll")
+	expect(t, "/poll", 503)
+	expect(t, "/other", 200)
+
+	close(release)
+	<-done
+	if want, have := 200, inFlight.Code; have != want {
+		t.Errorf("expected the in-flight request to complete with %d, found %d", want, have)
+	}
+
+	mux.UndrainRoute("GET", "/poll")
+	expect(t, "/poll", 200)
+}
+
+func TestSetRouteEnabled(t *testing.T) {
+	mux := New()
+	mux.Handle("GET", "/feature", serve(200))
+	mux.Handle("POST", "/feature", serve(201))
+	mux.Handle("GET", "/other", serve(202))
+
+	expect := func(t *testing.T, method, path string, want int) {
+		t.Helper()
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, httptest.NewRequest(method, path, nil))
+		if have := rw.Code; have != want {
+			t.Errorf("%s %s: expected status code %d, found %d", method, path, want, have)
+		}
+	}
+
+	mux.SetRouteEnabled("GET", "/feature", false)
+	expect(t, "GET", "/feature", 405)
+	expect(t, "POST", "/feature", 201)
+
+	mux.SetRouteEnabled("POST", "/feature", false)
+	expect(t, "GET", "/feature", 404)
+	expect(t, "POST", "/feature", 404)
+	expect(t, "GET", "/other", 202)
+
+	mux.SetRouteEnabled("GET", "/feature", true)
+	mux.SetRouteEnabled("POST", "/feature", true)
+	expect(t, "GET", "/feature", 200)
+	expect(t, "POST", "/feature", 201)
+
+	t.Run("falls through", func(t *testing.T) {
+		mux := New()
+		mux.Handle("GET", "/", serve(201))
+		mux.Handle("GET", "/x", serve(200))
+		mux.Handle("GET", "/x/", serve(202))
+
+		mux.SetRouteEnabled("GET", "/x", false)
+		expect := func(path string, want int) {
+			t.Helper()
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest("GET", path, nil))
+			if have := rw.Code; have != want {
+				t.Errorf("GET %s: expected status code %d, found %d", path, want, have)
+			}
+		}
+		expect("/x/y", 202)
+
+		mux.SetRouteEnabled("GET", "/x/", false)
+		expect("/x", 201)
+		expect("/x/y", 201)
+	})
+}
+
+func TestDeregister(t *testing.T) {
+	mux := New()
+	mux.Handle("GET", "/items/", serve(200))
+	mux.Handle("GET", "/items/special", serve(201))
+	mux.Handle("POST", "/items/", serve(202))
+
+	if !mux.Deregister("GET", "/items/special") {
+		t.Fatalf("expected the route to exist")
+	}
+	if mux.Deregister("GET", "/items/special") {
+		t.Errorf("expected the route to be gone")
+	}
+	if !mux.Deregister("POST", "/items/") {
+		t.Fatalf("expected the route to exist")
+	}
+
+	for _, tc := range [...]struct {
+		method, path string
+		expectedCode int
+	}{
+		{"GET", "/items/special", 200},
+		{"POST", "/items/", 405},
+	} {
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, httptest.NewRequest(tc.method, tc.path, nil))
+		if want, have := tc.expectedCode, rw.Code; have != want {
+			t.Errorf("%s %s: expected status code %d, found %d", tc.method, tc.path, want, have)
+		}
+	}
+	if mux.HasMethod("POST") {
+		t.Errorf("expected no POST routes")
+	}
+}
+
+func TestHandleCancelable(t *testing.T) {
+	mux := New()
+	cancel := mux.HandleCancelable("GET", "/temporary", serve(200))
+
+	get := func() int {
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, httptest.NewRequest("GET", "/temporary", nil))
+		return rw.Code
+	}
+
+	if want, have := 200, get(); have != want {
+		t.Errorf("expected status code %d, found %d", want, have)
+	}
+
+	cancel()
+	if want, have := 404, get(); have != want {
+		t.Errorf("expected status code %d, found %d", want, have)
+	}
+
+	mux.Handle("GET", "/temporary", serve(201))
+	cancel()
+	if want, have := 201, get(); have != want {
+		t.Errorf("expected status code %d after registering again, found %d", want, have)
+	}
+}
+
+func TestLen(t *testing.T) {
+	mux := New()
+	if want, have := 0, mux.Len(); have != want {
+		t.Errorf("expected %d routes, found %d", want, have)
+	}
+
+	mux.Handle("GET", "/", serve(200))
+	mux.Handle("GET", "/items/", serve(200))
+	mux.Handle("POST", "/items/", serve(200))
+	mux.HandlePort("8080", "GET", "/admin", serve(200))
+
+	func() {
+		defer func() { recover() }()
+		mux.Handle("GET", "/items/", serve(200))
+	}()
+
+	if want, have := 3, mux.Len(); have != want {
+		t.Errorf("expected %d routes, found %d", want, have)
+	}
+	if want, have := len(mux.Routes()), mux.Len(); have != want {
+		t.Errorf("expected Len to equal len(Routes()) = %d, found %d", want, have)
+	}
+}
+
+func TestHandleTTL(t *testing.T) {
+	get := func(mux *ServeMux) int {
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, httptest.NewRequest("GET", "/callback", nil))
+		return rw.Code
+	}
+
+	t.Run("expires", func(t *testing.T) {
+		mux := New()
+		mux.HandleTTL("GET", "/callback", serve(200), 50*time.Millisecond)
+
+		if want, have := 200, get(mux); have != want {
+			t.Errorf("expected status code %d, found %d", want, have)
+		}
+
+		deadline := time.Now().Add(2 * time.Second)
+		for get(mux) != 404 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if want, have := 404, get(mux); have != want {
+			t.Errorf("expected status code %d after expiry, found %d", want, have)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		mux := New()
+		cancel := mux.HandleTTL("GET", "/callback", serve(200), time.Hour)
+		cancel()
+
+		if want, have := 404, get(mux); have != want {
+			t.Errorf("expected status code %d, found %d", want, have)
+		}
+	})
+}
+
+func TestHandleWindow(t *testing.T) {
+	start := time.Date(2026, 6, 1, 18, 0, 0, 0, time.UTC)
+	end := start.Add(2 * time.Hour)
+
+	testCases := [...]struct {
+		name         string
+		now          time.Time
+		closed       http.Handler
+		expectedCode int
+	}{
+		{"before", start.Add(-time.Second), nil, 404},
+		{"at start", start, nil, 200},
+		{"during", start.Add(time.Hour), nil, 200},
+		{"at end", end, nil, 404},
+		{"after", end.Add(time.Hour), nil, 404},
+		{"after with closed handler", end.Add(time.Hour), ForbiddenHandler, 403},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mux := New()
+			mux.Now = func() time.Time { return tc.now }
+			mux.WindowClosedHandler = tc.closed
+			mux.HandleWindow("POST", "/vote", start, end, serve(200))
+
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest("POST", "/vote", nil))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	testCases := [...]struct {
+		name         string
+		routes       [][2]string
+		expectedJSON string
+	}{
+		{"empty", nil, `[]`},
+		{"routes", [][2]string{{"POST", "/items"}, {"GET", "/items/"}, {"GET", "example.com/"}}, `[{"method":"GET","pattern":"/items/"},{"method":"GET","pattern":"example.com/"},{"method":"POST","pattern":"/items"}]`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mux := New()
+			for _, route := range tc.routes {
+				mux.Handle(route[0], route[1], serve(200))
+			}
+			b, err := json.Marshal(mux)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want, have := tc.expectedJSON, string(b); have != want {
+				t.Errorf("expected JSON %s, found %s", want, have)
+			}
+		})
+	}
+}
+
+func TestHandlePolicy(t *testing.T) {
+	testCases := [...]struct {
+		name          string
+		policy        DuplicatePolicy
+		expectedPanic bool
+		expectedCode  int
+	}{
+		{"panic on duplicate", PanicOnDup, true, 200},
+		{"overwrite", Overwrite, false, 201},
+		{"ignore", Ignore, false, 200},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mux := New()
+			mux.Handle("GET", "/some/path", serve(200))
+
+			func() {
+				defer func() {
+					if want, have := tc.expectedPanic, recover() != nil; have != want {
+						t.Errorf("expected a panic: %t, found %t", want, have)
+					}
+				}()
+				mux.HandlePolicy("GET", "/some/path", serve(201), tc.policy)
+			}()
+
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest("GET", "/some/path", nil))
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+
+		t.Run(tc.name+" without a registration", func(t *testing.T) {
+			mux := New()
+			mux.HandlePolicy("GET", "/some/path", serve(201), tc.policy)
+
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest("GET", "/some/path", nil))
+			if want, have := 201, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package methodmux
+
+import (
+	"crypto/x509"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// HandleMTLS registers the handler for the given method and pattern,
+// only serving the requests that come with a TLS client certificate.
+// If validate is not nil, it is called with the leaf certificate of the
+// client, and the request is only served if it returns nil. Other
+// requests are answered with 403 "Forbidden".
+//
+// The certificates are not verified against any certificate authority
+// by HandleMTLS; that is the job of the tls.Config of the server.
+func (mux *ServeMux) HandleMTLS(method, pattern string, handler http.Handler, validate func(*x509.Certificate) error) {
+	mux.Handle(method, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			ForbiddenHandler.ServeHTTP(w, r)
+			return
+		}
+		if validate != nil && validate(r.TLS.PeerCertificates[0]) != nil {
+			ForbiddenHandler.ServeHTTP(w, r)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+}
+
+// HandleCIDR registers the handler for the given method and pattern,
+// only serving the requests whose client IP address is in one of the
+// allowed networks. Other requests are answered with 403 "Forbidden".
+// The client IP address is read from r.RemoteAddr or, if the request
+// has it, from the header named by ClientIPHeader.
+func (mux *ServeMux) HandleCIDR(method, pattern string, allowed []*net.IPNet, handler http.Handler) {
+	mux.Handle(method, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := mux.clientIP(r)
+		for _, network := range allowed {
+			if ip != nil && network.Contains(ip) {
+				handler.ServeHTTP(w, r)
+				return
+			}
+		}
+		ForbiddenHandler.ServeHTTP(w, r)
+	}))
+}
+
+// clientIP returns the IP address of the client that sent r, or nil if
+// it cannot be parsed. If ClientIPHeader is set and present in r, its
+// last comma-separated entry is used: proxies append the address of
+// their client to X-Forwarded-For, so the earlier entries are whatever
+// the client sent. Otherwise, the port is stripped from r.RemoteAddr.
+func (mux *ServeMux) clientIP(r *http.Request) net.IP {
+	if mux.ClientIPHeader != "" {
+		if values := r.Header.Values(mux.ClientIPHeader); len(values) > 0 {
+			value := values[len(values)-1]
+			return net.ParseIP(strings.TrimSpace(value[strings.LastIndex(value, ",")+1:]))
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// HandleSameSite registers the handler for the given method and
+// pattern, only serving the requests that a browser reports as coming
+// from the same origin or site, with a Sec-Fetch-Site header of
+// "same-origin" or "same-site". Other requests, including cross-site
+// ones, are answered with 403 "Forbidden". The requests without the
+// header, such as those of older browsers and non-browser clients, are
+// served unless RequireFetchMetadata is set.
+func (mux *ServeMux) HandleSameSite(method, pattern string, handler http.Handler) {
+	mux.Handle(method, pattern, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Sec-Fetch-Site") {
+		case "same-origin", "same-site":
+		case "":
+			if mux.RequireFetchMetadata {
+				ForbiddenHandler.ServeHTTP(w, r)
+				return
+			}
+		default:
+			ForbiddenHandler.ServeHTTP(w, r)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+}
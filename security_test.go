@@ -0,0 +1,141 @@
+package methodmux_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/pierreprinetti/go-methodmux"
+)
+
+func TestHandleMTLS(t *testing.T) {
+	clientCert := func(cn string) *tls.ConnectionState {
+		return &tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: cn}}},
+		}
+	}
+
+	testCases := [...]struct {
+		name         string
+		tls          *tls.ConnectionState
+		expectedCode int
+	}{
+		{"plain HTTP", nil, 403},
+		{"no client certificate", &tls.ConnectionState{}, 403},
+		{"valid client certificate", clientCert("trusted"), 200},
+		{"rejected client certificate", clientCert("untrusted"), 403},
+	}
+
+	mux := New()
+	mux.HandleMTLS("GET", "/any", serve(200), nil)
+	mux.HandleMTLS("GET", "/validated", serve(200), func(cert *x509.Certificate) error {
+		if cert.Subject.CommonName != "trusted" {
+			return errors.New("untrusted client")
+		}
+		return nil
+	})
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/validated", nil)
+			req.TLS = tc.tls
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, req)
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+
+	t.Run("without validator", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/any", nil)
+		req.TLS = clientCert("untrusted")
+		rw := httptest.NewRecorder()
+		mux.ServeHTTP(rw, req)
+		if want, have := 200, rw.Code; have != want {
+			t.Errorf("expected status code %d, found %d", want, have)
+		}
+	})
+}
+
+func TestHandleCIDR(t *testing.T) {
+	_, internal, _ := net.ParseCIDR("10.0.0.0/8")
+	_, loopback, _ := net.ParseCIDR("::1/128")
+
+	testCases := [...]struct {
+		name           string
+		clientIPHeader string
+		remoteAddr     string
+		forwardedFor   string
+		expectedCode   int
+	}{
+		{"allowed IP", "", "10.1.2.3:4567", "", 200},
+		{"allowed IPv6", "", "[::1]:4567", "", 200},
+		{"denied IP", "", "192.0.2.1:4567", "", 403},
+		{"no port", "", "10.1.2.3", "", 200},
+		{"invalid address", "", "invalid", "", 403},
+		{"forwarded header ignored", "", "192.0.2.1:4567", "10.1.2.3", 403},
+		{"allowed forwarded IP", "X-Forwarded-For", "192.0.2.1:4567", "192.0.2.2, 10.1.2.3", 200},
+		{"spoofed forwarded IP", "X-Forwarded-For", "192.0.2.1:4567", "10.0.0.1, 192.0.2.2", 403},
+		{"denied forwarded IP", "X-Forwarded-For", "10.1.2.3:4567", "192.0.2.1", 403},
+		{"missing forwarded header", "X-Forwarded-For", "10.1.2.3:4567", "", 200},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mux := New()
+			mux.ClientIPHeader = tc.clientIPHeader
+			mux.HandleCIDR("GET", "/admin", []*net.IPNet{internal, loopback}, serve(200))
+
+			req := httptest.NewRequest("GET", "/admin", nil)
+			req.RemoteAddr = tc.remoteAddr
+			if tc.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tc.forwardedFor)
+			}
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, req)
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+}
+
+func TestHandleSameSite(t *testing.T) {
+	testCases := [...]struct {
+		name         string
+		requireFM    bool
+		fetchSite    string
+		expectedCode int
+	}{
+		{"same origin", false, "same-origin", 200},
+		{"same site", false, "same-site", 200},
+		{"cross site", false, "cross-site", 403},
+		{"user initiated", false, "none", 403},
+		{"missing header", false, "", 200},
+		{"missing header, required", true, "", 403},
+		{"same origin, required", true, "same-origin", 200},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mux := New()
+			mux.RequireFetchMetadata = tc.requireFM
+			mux.HandleSameSite("POST", "/transfer", serve(200))
+
+			req := httptest.NewRequest("POST", "/transfer", nil)
+			if tc.fetchSite != "" {
+				req.Header.Set("Sec-Fetch-Site", tc.fetchSite)
+			}
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, req)
+			if want, have := tc.expectedCode, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		})
+	}
+}
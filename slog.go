@@ -0,0 +1,39 @@
+package methodmux
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// log emits a dispatch event for r to Slog, with the method, host and
+// path of r and the given attributes.
+func (mux *ServeMux) log(r *http.Request, level slog.Level, msg string, attrs ...slog.Attr) {
+	if mux.Slog == nil || !mux.Slog.Enabled(r.Context(), level) {
+		return
+	}
+
+	attrs = append([]slog.Attr{
+		slog.String("method", r.Method),
+		slog.String("host", r.Host),
+		slog.String("path", r.URL.Path),
+	}, attrs...)
+	mux.Slog.LogAttrs(r.Context(), level, msg, attrs...)
+}
+
+// logMatch emits the dispatch event for r resolving to m.
+func (mux *ServeMux) logMatch(r *http.Request, m match) {
+	if mux.Slog == nil {
+		return
+	}
+
+	switch m.kind {
+	case MatchRoute, MatchCustom:
+		mux.log(r, slog.LevelDebug, "matched", slog.String("pattern", m.pattern))
+	case MatchRedirect:
+		mux.log(r, slog.LevelDebug, "redirected", slog.String("pattern", m.pattern), slog.String("target", m.target))
+	case MatchNotFound:
+		mux.log(r, slog.LevelDebug, "not found")
+	case MatchMethodNotAllowed:
+		mux.log(r, slog.LevelInfo, "method not allowed")
+	}
+}
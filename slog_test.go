@@ -0,0 +1,80 @@
+package methodmux_test
+
+import (
+	"context"
+	"log/slog"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	. "github.com/pierreprinetti/go-methodmux"
+)
+
+// recordHandler is a slog.Handler that keeps the records it handles.
+type recordHandler struct {
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+func (h *recordHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *recordHandler) Handle(_ context.Context, r slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.records = append(h.records, r)
+	return nil
+}
+
+func (h *recordHandler) WithAttrs([]slog.Attr) slog.Handler { return h }
+func (h *recordHandler) WithGroup(string) slog.Handler      { return h }
+
+func TestSlog(t *testing.T) {
+	testCases := [...]struct {
+		method          string
+		target          string
+		expectedLevel   slog.Level
+		expectedMessage string
+		expectedAttrs   map[string]string
+	}{
+		{"GET", "/items", slog.LevelDebug, "matched", map[string]string{"method": "GET", "path": "/items", "pattern": "/items"}},
+		{"GET", "/dir", slog.LevelDebug, "redirected", map[string]string{"pattern": "/dir/", "target": "/dir/"}},
+		{"GET", "/missing", slog.LevelDebug, "not found", map[string]string{"path": "/missing"}},
+		{"POST", "/items", slog.LevelInfo, "method not allowed", map[string]string{"method": "POST"}},
+		{"OPTIONS", "*", slog.LevelWarn, "bad request", map[string]string{"reason": "asterisk-form request"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.method+" "+tc.target, func(t *testing.T) {
+			h := new(recordHandler)
+
+			mux := New()
+			mux.Slog = slog.New(h)
+			mux.Handle("GET", "/items", serve(200))
+			mux.Handle("GET", "/dir/", serve(200))
+
+			mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tc.method, tc.target, nil))
+
+			if want, have := 1, len(h.records); have != want {
+				t.Fatalf("expected %d record, found %d", want, have)
+			}
+			record := h.records[0]
+			if want, have := tc.expectedLevel, record.Level; have != want {
+				t.Errorf("expected level %v, found %v", want, have)
+			}
+			if want, have := tc.expectedMessage, record.Message; have != want {
+				t.Errorf("expected message %q, found %q", want, have)
+			}
+
+			attrs := make(map[string]string)
+			record.Attrs(func(a slog.Attr) bool {
+				attrs[a.Key] = a.Value.String()
+				return true
+			})
+			for key, want := range tc.expectedAttrs {
+				if have := attrs[key]; have != want {
+					t.Errorf("expected attribute %s=%q, found %q", key, want, have)
+				}
+			}
+		})
+	}
+}
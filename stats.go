@@ -0,0 +1,79 @@
+package methodmux
+
+import (
+	"expvar"
+	"sync/atomic"
+)
+
+// Stats holds the request counters of a ServeMux. Only the requests
+// served with ServeHTTP are counted.
+type Stats struct {
+	// Requests is the number of requests served.
+	Requests uint64 `json:"requests"`
+
+	// NotFound is the number of requests that matched no handler.
+	NotFound uint64 `json:"not_found"`
+
+	// MethodNotAllowed is the number of requests that matched a
+	// handler registered with another method.
+	MethodNotAllowed uint64 `json:"method_not_allowed"`
+
+	// Disconnects is the number of requests to the handlers registered
+	// with HandleCancelOnDisconnect whose context was done before the
+	// handler returned.
+	Disconnects uint64 `json:"disconnects"`
+
+	// Hits is the number of requests served by each handler
+	// registered with Handle, keyed by method and pattern separated by
+	// a space, as in "GET /items/".
+	Hits map[string]uint64 `json:"hits"`
+}
+
+// counters holds the aggregate request counters of a ServeMux.
+type counters struct {
+	requests         atomic.Uint64
+	notFound         atomic.Uint64
+	methodNotAllowed atomic.Uint64
+	disconnects      atomic.Uint64
+}
+
+// count updates the counters with the outcome of a request.
+func (mux *ServeMux) count(m match) {
+	switch m.kind {
+	case MatchRoute:
+		m.route.hits.Add(1)
+	case MatchNotFound:
+		mux.stats.notFound.Add(1)
+	case MatchMethodNotAllowed:
+		mux.stats.methodNotAllowed.Add(1)
+	}
+}
+
+// Stats returns a snapshot of the request counters of mux.
+func (mux *ServeMux) Stats() Stats {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+
+	stats := Stats{
+		Requests:         mux.stats.requests.Load(),
+		NotFound:         mux.stats.notFound.Load(),
+		MethodNotAllowed: mux.stats.methodNotAllowed.Load(),
+		Disconnects:      mux.stats.disconnects.Load(),
+		Hits:             make(map[string]uint64),
+	}
+	for method, patterns := range mux.routes {
+		for pattern, rt := range patterns {
+			stats.Hits[method+" "+pattern] = rt.hits.Load()
+		}
+	}
+	return stats
+}
+
+// PublishExpvar publishes the request counters of mux as an expvar
+// variable with the given name, rendered as the JSON encoding of Stats.
+// Like expvar.Publish, it panics if the name is already in use.
+func (mux *ServeMux) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return mux.Stats()
+	}))
+}
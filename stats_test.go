@@ -0,0 +1,99 @@
+package methodmux_test
+
+import (
+	"encoding/json"
+	"expvar"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	. "github.com/pierreprinetti/go-methodmux"
+)
+
+func TestStats(t *testing.T) {
+	mux := New()
+	mux.Handle("GET", "/items/", serve(200))
+	mux.Handle("POST", "/items/", serve(201))
+
+	for _, req := range [...]struct{ method, path string }{
+		{"GET", "/items/"},
+		{"GET", "/items/1"},
+		{"POST", "/items/"},
+		{"DELETE", "/items/"},
+		{"GET", "/missing"},
+	} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.path, nil))
+	}
+
+	want := Stats{
+		Requests:         5,
+		NotFound:         1,
+		MethodNotAllowed: 1,
+		Hits: map[string]uint64{
+			"GET /items/":  2,
+			"POST /items/": 1,
+		},
+	}
+	if have := mux.Stats(); !reflect.DeepEqual(have, want) {
+		t.Errorf("expected stats %+v, found %+v", want, have)
+	}
+
+	t.Run("misdirected requests", func(t *testing.T) {
+		mux := New()
+		mux.KnownHosts = []string{"example.com"}
+		mux.Handle("GET", "other.com/items/", serve(200))
+
+		for _, path := range [...]string{"/items/", "/missing"} {
+			req := httptest.NewRequest("GET", path, nil)
+			req.Host = "other.com"
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, req)
+			if want, have := 421, rw.Code; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+		}
+
+		want := Stats{
+			Requests: 2,
+			Hits:     map[string]uint64{"GET other.com/items/": 0},
+		}
+		if have := mux.Stats(); !reflect.DeepEqual(have, want) {
+			t.Errorf("expected stats %+v, found %+v", want, have)
+		}
+	})
+}
+
+func TestPublishExpvar(t *testing.T) {
+	mux := New()
+	mux.Handle("GET", "/items/", serve(200))
+	mux.PublishExpvar("methodmux_test")
+
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/items/", nil))
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/missing", nil))
+
+	v := expvar.Get("methodmux_test")
+	if v == nil {
+		t.Fatalf("expected the variable to be published")
+	}
+	var have Stats
+	if err := json.Unmarshal([]byte(v.String()), &have); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := Stats{
+		Requests: 2,
+		NotFound: 1,
+		Hits:     map[string]uint64{"GET /items/": 1},
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Errorf("expected stats %+v, found %+v", want, have)
+	}
+
+	t.Run("panics on a duplicate name", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected PublishExpvar to panic")
+			}
+		}()
+		New().PublishExpvar("methodmux_test")
+	})
+}
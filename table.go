@@ -0,0 +1,60 @@
+package methodmux
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// LoadTable registers the routes listed in the routing table read from
+// r. Each line of the table holds a method, a pattern and a handler
+// name, separated by spaces, such as:
+//
+//	GET /items/ listItems
+//
+// Handler names are resolved with lookup. Blank lines and lines
+// starting with "#" are ignored.
+//
+// The routes are registered with HandleBatch, so that either all of
+// them are registered or none is. If any line is malformed or names an
+// unknown handler, LoadTable registers nothing and returns an error
+// reporting all the invalid lines.
+func (mux *ServeMux) LoadTable(r io.Reader, lookup func(name string) (http.Handler, bool)) error {
+	var (
+		routes []Route
+		errs   []error
+	)
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			errs = append(errs, fmt.Errorf("methodmux: line %d: expected method, pattern and handler name, found %q", n, line))
+			continue
+		}
+
+		handler, ok := lookup(fields[2])
+		if !ok {
+			errs = append(errs, fmt.Errorf("methodmux: line %d: unknown handler %q", n, fields[2]))
+			continue
+		}
+
+		routes = append(routes, Route{Method: fields[0], Pattern: fields[1], Handler: handler})
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, fmt.Errorf("methodmux: reading the table: %w", err))
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return mux.HandleBatch(routes)
+}
@@ -0,0 +1,60 @@
+package methodmux_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/pierreprinetti/go-methodmux"
+)
+
+func TestLoadTable(t *testing.T) {
+	handlers := map[string]http.Handler{
+		"listItems":  serve(200),
+		"createItem": serve(201),
+	}
+	lookup := func(name string) (http.Handler, bool) {
+		h, ok := handlers[name]
+		return h, ok
+	}
+
+	t.Run("valid table", func(t *testing.T) {
+		mux := New()
+		err := mux.LoadTable(strings.NewReader(`
+# items
+GET   /items/  listItems
+POST  /items/  createItem
+`), lookup)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for method, want := range map[string]int{"GET": 200, "POST": 201, "PUT": 405} {
+			rw := httptest.NewRecorder()
+			mux.ServeHTTP(rw, httptest.NewRequest(method, "/items/", nil))
+			if have := rw.Code; have != want {
+				t.Errorf("%s: expected status code %d, found %d", method, want, have)
+			}
+		}
+	})
+
+	t.Run("invalid table", func(t *testing.T) {
+		mux := New()
+		err := mux.LoadTable(strings.NewReader(`GET /items/ listItems
+POST /items/ deleteItem
+PUT /items/
+`), lookup)
+		if err == nil {
+			t.Fatalf("expected an error")
+		}
+		for _, want := range []string{"line 2", "deleteItem", "line 3"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("expected the error to mention %q, found %q", want, err)
+			}
+		}
+		if want, have := 0, len(mux.Routes()); have != want {
+			t.Errorf("expected %d routes, found %d", want, have)
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package methodmux
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// roundTripper is the http.RoundTripper returned by RoundTripper.
+type roundTripper struct {
+	mux *ServeMux
+}
+
+// RoundTripper returns an http.RoundTripper that serves the requests
+// with mux directly, without a network connection. It is meant for
+// tests that exercise mux with an http.Client:
+//
+//	client := &http.Client{Transport: mux.RoundTripper()}
+//	res, err := client.Get("http://example.com/items/")
+//
+// The requests are served in the calling goroutine, and the response
+// is buffered in full before RoundTrip returns.
+func (mux *ServeMux) RoundTripper() http.RoundTripper {
+	return roundTripper{mux: mux}
+}
+
+func (rt roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+
+	// Make the request look like one received by a server.
+	r := req.Clone(req.Context())
+	if r.Body == nil {
+		r.Body = http.NoBody
+	}
+	if r.Host == "" {
+		r.Host = req.URL.Host
+	}
+	r.RequestURI = req.URL.RequestURI()
+	if r.RemoteAddr == "" {
+		r.RemoteAddr = "192.0.2.1:1234"
+	}
+
+	rw := &bufferedResponse{header: make(http.Header)}
+	rt.mux.ServeHTTP(rw, r)
+	return rw.response(req), nil
+}
+
+// bufferedResponse is an http.ResponseWriter that holds the response
+// in memory.
+type bufferedResponse struct {
+	header http.Header
+	sent   http.Header // header as of WriteHeader
+	code   int
+	body   bytes.Buffer
+}
+
+func (w *bufferedResponse) Header() http.Header {
+	return w.header
+}
+
+func (w *bufferedResponse) WriteHeader(code int) {
+	if w.code != 0 || code < 200 {
+		return
+	}
+	w.code = code
+	w.sent = w.header.Clone()
+}
+
+func (w *bufferedResponse) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		if w.header.Get("Content-Type") == "" {
+			w.header.Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.body.Write(b)
+}
+
+// Flush implements http.Flusher. As the response is buffered, it only
+// commits the header.
+func (w *bufferedResponse) Flush() {
+	if w.code == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// response returns the response to req.
+func (w *bufferedResponse) response(req *http.Request) *http.Response {
+	if w.code == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", w.code, http.StatusText(w.code)),
+		StatusCode:    w.code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        w.sent,
+		Body:          io.NopCloser(bytes.NewReader(w.body.Bytes())),
+		ContentLength: int64(w.body.Len()),
+		Request:       req,
+	}
+}
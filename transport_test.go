@@ -0,0 +1,88 @@
+package methodmux_test
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	. "github.com/pierreprinetti/go-methodmux"
+)
+
+func TestRoundTripper(t *testing.T) {
+	mux := New()
+	mux.HandleFunc("GET", "example.com/items/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("items " + r.URL.Query().Get("page")))
+	})
+	mux.HandleFunc("POST", "/items/", func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.WriteHeader(201)
+		w.Write(b)
+	})
+
+	client := &http.Client{Transport: mux.RoundTripper()}
+
+	testCases := [...]struct {
+		name         string
+		do           func() (*http.Response, error)
+		expectedCode int
+		expectedBody string
+	}{
+		{"get", func() (*http.Response, error) {
+			return client.Get("http://example.com/items/?page=2")
+		}, 200, "items 2"},
+		{"post", func() (*http.Response, error) {
+			return client.Post("http://example.com/items/", "text/plain", strings.NewReader("new item"))
+		}, 201, "new item"},
+		{"method not allowed", func() (*http.Response, error) {
+			req, _ := http.NewRequest("DELETE", "http://example.com/items/", nil)
+			return client.Do(req)
+		}, 405, "Method Not Allowed\n"},
+		{"other host", func() (*http.Response, error) {
+			return client.Get("http://other.example.com/items/")
+		}, 405, "Method Not Allowed\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			res, err := tc.do()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer res.Body.Close()
+
+			if want, have := tc.expectedCode, res.StatusCode; have != want {
+				t.Errorf("expected status code %d, found %d", want, have)
+			}
+			b, _ := io.ReadAll(res.Body)
+			if want, have := tc.expectedBody, string(b); have != want {
+				t.Errorf("expected body %q, found %q", want, have)
+			}
+		})
+	}
+
+	t.Run("closes the body", func(t *testing.T) {
+		body := &closeRecorder{Reader: strings.NewReader("ignored")}
+		req, _ := http.NewRequest("PUT", "http://example.com/missing", body)
+		res, err := mux.RoundTripper().RoundTrip(req)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		res.Body.Close()
+		if !body.closed {
+			t.Errorf("expected the request body to be closed")
+		}
+	})
+}
+
+// closeRecorder is a request body that records whether it is closed.
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (b *closeRecorder) Close() error {
+	b.closed = true
+	return nil
+}
@@ -0,0 +1,116 @@
+package methodmux
+
+import (
+	"sort"
+	"strings"
+)
+
+// WarningKind identifies the kind of a Warning.
+type WarningKind int
+
+const (
+	// HostShadowing reports a pattern without a host, such as
+	// "/items/new", that never serves the requests for a host because
+	// a subtree pattern for that host, such as "example.com/", matches
+	// them first.
+	HostShadowing WarningKind = iota + 1
+
+	// TrailingSlashPair reports a path registered both with and
+	// without a trailing slash, such as "/items" and "/items/", so
+	// that "/items" is served rather than redirected to the subtree.
+	// This is a common and deliberate idiom, so Validate only reports
+	// it if ValidateTrailingSlash is set.
+	TrailingSlashPair
+)
+
+// Warning is a possible mistake in the route table, reported by
+// Validate.
+type Warning struct {
+	// Kind is the kind of the problem.
+	Kind WarningKind
+
+	// Method is the method the patterns are registered with.
+	Method string
+
+	// Pattern is the pattern that may not behave as intended.
+	Pattern string
+
+	// Other is the pattern that interferes with Pattern.
+	Other string
+
+	// Reason describes the problem.
+	Reason string
+}
+
+func (w Warning) String() string {
+	return w.Method + " " + w.Pattern + ": " + w.Reason + " " + w.Other
+}
+
+// Validate analyzes the patterns registered with Handle and returns the
+// combinations that are likely to surprise, sorted by method and
+// pattern. See WarningKind for the combinations reported.
+//
+// Validate is advisory: it does not change how requests are routed.
+func (mux *ServeMux) Validate() []Warning {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+
+	var warnings []Warning
+	for method, routes := range mux.routes {
+		for pattern := range routes {
+			if pattern == "" || pattern[0] == '/' {
+				continue
+			}
+			// A host-qualified subtree shadows the more specific
+			// patterns without a host.
+			if !strings.HasSuffix(pattern, "/") {
+				continue
+			}
+			host, path := pattern[:len(pattern)-len(patternPath(pattern))], patternPath(pattern)
+			for other := range routes {
+				if other[0] != '/' || len(other) <= len(path) || !strings.HasPrefix(other, path) {
+					continue
+				}
+				if _, exists := routes[host+other]; exists {
+					continue
+				}
+				warnings = append(warnings, Warning{
+					Kind:    HostShadowing,
+					Method:  method,
+					Pattern: other,
+					Other:   pattern,
+					Reason:  "is shadowed for host " + host + " by",
+				})
+			}
+		}
+
+		if !mux.ValidateTrailingSlash {
+			continue
+		}
+		for pattern := range routes {
+			if pattern == "" || strings.HasSuffix(pattern, "/") {
+				continue
+			}
+			if _, exists := routes[pattern+"/"]; exists {
+				warnings = append(warnings, Warning{
+					Kind:    TrailingSlashPair,
+					Method:  method,
+					Pattern: pattern,
+					Other:   pattern + "/",
+					Reason:  "is served instead of redirecting to",
+				})
+			}
+		}
+	}
+
+	sort.Slice(warnings, func(i, j int) bool {
+		if warnings[i].Method != warnings[j].Method {
+			return warnings[i].Method < warnings[j].Method
+		}
+		if warnings[i].Pattern != warnings[j].Pattern {
+			return warnings[i].Pattern < warnings[j].Pattern
+		}
+		return warnings[i].Other < warnings[j].Other
+	})
+	return warnings
+}
@@ -0,0 +1,63 @@
+package methodmux_test
+
+import (
+	"testing"
+
+	. "github.com/pierreprinetti/go-methodmux"
+)
+
+func TestValidate(t *testing.T) {
+	t.Run("clean", func(t *testing.T) {
+		mux := New()
+		mux.Handle("GET", "/", serve(200))
+		mux.Handle("GET", "/items/", serve(200))
+		mux.Handle("GET", "/items/new", serve(200))
+		mux.Handle("GET", "api.example.com/items", serve(200))
+
+		if warnings := mux.Validate(); len(warnings) != 0 {
+			t.Errorf("expected no warnings, found %v", warnings)
+		}
+	})
+
+	t.Run("shadowing", func(t *testing.T) {
+		mux := New()
+		mux.Handle("GET", "/", serve(200))
+		mux.Handle("GET", "/items/new", serve(200))
+		mux.Handle("GET", "/search", serve(200))
+		mux.Handle("GET", "example.com/items/", serve(200))
+		mux.Handle("GET", "example.com/search", serve(200))
+		mux.Handle("POST", "/items", serve(200))
+		mux.Handle("POST", "/items/", serve(200))
+
+		expected := []Warning{
+			{Kind: HostShadowing, Method: "GET", Pattern: "/items/new", Other: "example.com/items/", Reason: "is shadowed for host example.com by"},
+		}
+
+		warnings := mux.Validate()
+		if want, have := len(expected), len(warnings); have != want {
+			t.Fatalf("expected %d warnings, found %d: %v", want, have, warnings)
+		}
+		for i := range expected {
+			if want, have := expected[i], warnings[i]; have != want {
+				t.Errorf("expected warning %q, found %q", want, have)
+			}
+		}
+	})
+
+	t.Run("trailing slash", func(t *testing.T) {
+		mux := New()
+		mux.Handle("POST", "/items", serve(200))
+		mux.Handle("POST", "/items/", serve(200))
+
+		if warnings := mux.Validate(); len(warnings) != 0 {
+			t.Errorf("expected no warnings, found %v", warnings)
+		}
+
+		mux.ValidateTrailingSlash = true
+		expected := Warning{Kind: TrailingSlashPair, Method: "POST", Pattern: "/items", Other: "/items/", Reason: "is served instead of redirecting to"}
+		warnings := mux.Validate()
+		if len(warnings) != 1 || warnings[0] != expected {
+			t.Errorf("expected warning %q, found %v", expected, warnings)
+		}
+	})
+}
@@ -0,0 +1,486 @@
+package methodmux
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// statusWriter is an http.ResponseWriter that records the status code
+// and the number of body bytes written through it.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 && code >= 200 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher if the underlying ResponseWriter does.
+func (w *statusWriter) Flush() {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter, for use by
+// http.ResponseController.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// withOptional returns w, extended with the http.Hijacker and
+// http.Pusher implementations of the underlying ResponseWriter, if it
+// has them, so that the handlers that detect them are not misled.
+func (w *statusWriter) withOptional() http.ResponseWriter {
+	_, hijacker := w.ResponseWriter.(http.Hijacker)
+	_, pusher := w.ResponseWriter.(http.Pusher)
+	switch {
+	case hijacker && pusher:
+		return hijackPushStatusWriter{w}
+	case hijacker:
+		return hijackStatusWriter{w}
+	case pusher:
+		return pushStatusWriter{w}
+	}
+	return w
+}
+
+// recorder returns w. It is promoted by the extensions of statusWriter
+// returned by withOptional.
+func (w *statusWriter) recorder() *statusWriter {
+	return w
+}
+
+type hijackStatusWriter struct{ *statusWriter }
+
+func (w hijackStatusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+type pushStatusWriter struct{ *statusWriter }
+
+func (w pushStatusWriter) Push(target string, opts *http.PushOptions) error {
+	return w.ResponseWriter.(http.Pusher).Push(target, opts)
+}
+
+type hijackPushStatusWriter struct{ *statusWriter }
+
+func (w hijackPushStatusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+func (w hijackPushStatusWriter) Push(target string, opts *http.PushOptions) error {
+	return w.ResponseWriter.(http.Pusher).Push(target, opts)
+}
+
+// code returns the recorded status code, defaulting to 200 like the
+// server does for handlers that write nothing.
+func (w *statusWriter) code() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// errorPageWriter is an http.ResponseWriter that holds back the status
+// codes that have an error page, until the body is written.
+type errorPageWriter struct {
+	http.ResponseWriter
+	pages   map[int]http.Handler
+	pending int // the status code held back, if any
+	written bool
+}
+
+func (w *errorPageWriter) WriteHeader(code int) {
+	if w.written || w.pending != 0 {
+		return
+	}
+	if _, ok := w.pages[code]; ok {
+		w.pending = code
+		return
+	}
+	if code >= 200 {
+		w.written = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *errorPageWriter) Write(b []byte) (int, error) {
+	if len(b) > 0 {
+		w.commit()
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// commit sends the status code held back, if any.
+func (w *errorPageWriter) commit() {
+	if w.pending != 0 {
+		w.ResponseWriter.WriteHeader(w.pending)
+		w.pending = 0
+	}
+	w.written = true
+}
+
+// Flush implements http.Flusher if the underlying ResponseWriter does.
+// As the response is then streamed, it is no longer substituted.
+func (w *errorPageWriter) Flush() {
+	w.commit()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter, for use by
+// http.ResponseController.
+func (w *errorPageWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// codeWriter is an http.ResponseWriter that answers with the given
+// status code, regardless of the one written through it.
+type codeWriter struct {
+	http.ResponseWriter
+	code    int
+	written bool
+}
+
+func (w *codeWriter) WriteHeader(int) {
+	if !w.written {
+		w.written = true
+		w.ResponseWriter.WriteHeader(w.code)
+	}
+}
+
+func (w *codeWriter) Write(b []byte) (int, error) {
+	w.WriteHeader(w.code)
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap returns the underlying ResponseWriter, for use by
+// http.ResponseController.
+func (w *codeWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// minCompressSize is the size under which HandleCompressed responses
+// are not compressed, as gzip would not make them smaller.
+const minCompressSize = 1024
+
+// gzipWriter is an http.ResponseWriter that compresses the body with
+// gzip, unless it is small or already compressed. It buffers the start
+// of the body to tell.
+type gzipWriter struct {
+	http.ResponseWriter
+	status  int
+	buf     []byte
+	gz      *gzip.Writer
+	decided bool
+}
+
+func (w *gzipWriter) WriteHeader(code int) {
+	if w.decided || w.status != 0 {
+		return
+	}
+	if code < 200 {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+}
+
+func (w *gzipWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < minCompressSize {
+			return len(b), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// decide writes the header, compressing the body if compress is set and
+// the content type is not already compressed, and then the buffered
+// start of the body.
+func (w *gzipWriter) decide(compress bool) error {
+	w.decided = true
+
+	h := w.Header()
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+	if compress && h.Get("Content-Encoding") == "" && compressible(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	if len(w.buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.gz != nil {
+		_, err = w.gz.Write(w.buf)
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf)
+	}
+	w.buf = nil
+	return err
+}
+
+// Flush implements http.Flusher if the underlying ResponseWriter does.
+// As the response is then streamed, it is compressed regardless of its
+// size.
+func (w *gzipWriter) Flush() {
+	if !w.decided {
+		w.decide(true)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// close writes the buffered body, if any, and terminates the gzip
+// stream.
+func (w *gzipWriter) close() error {
+	if !w.decided {
+		if err := w.decide(false); err != nil {
+			return err
+		}
+	}
+	if w.gz != nil {
+		return w.gz.Close()
+	}
+	return nil
+}
+
+// Unwrap returns the underlying ResponseWriter, for use by
+// http.ResponseController.
+func (w *gzipWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// compressible reports whether the content type is worth compressing.
+func compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	switch {
+	case mediaType == "image/svg+xml":
+		return true
+	case strings.HasPrefix(mediaType, "image/"),
+		strings.HasPrefix(mediaType, "video/"),
+		strings.HasPrefix(mediaType, "audio/"),
+		strings.HasPrefix(mediaType, "font/woff"):
+		return false
+	}
+	switch mediaType {
+	case "application/gzip", "application/x-gzip", "application/zip", "application/zstd", "application/x-bzip2", "application/x-7z-compressed":
+		return false
+	}
+	return true
+}
+
+// headWriter is an http.ResponseWriter that discards the body, for
+// serving HEAD requests with GET handlers. It holds back the header
+// until the handler returns, so that it can set the Content-Length.
+type headWriter struct {
+	http.ResponseWriter
+	status    int
+	bytes     int64
+	committed bool
+}
+
+func (w *headWriter) WriteHeader(code int) {
+	if w.committed || w.status != 0 {
+		return
+	}
+	if code < 200 {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+}
+
+func (w *headWriter) Write(b []byte) (int, error) {
+	w.bytes += int64(len(b))
+	return len(b), nil
+}
+
+// Flush implements http.Flusher if the underlying ResponseWriter does.
+func (w *headWriter) Flush() {
+	w.commit()
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// commit sends the header held back.
+func (w *headWriter) commit() {
+	if w.committed {
+		return
+	}
+	w.committed = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// finish sets the Content-Length, if the handler wrote a body without
+// setting it, and sends the header.
+func (w *headWriter) finish() {
+	if !w.committed && w.bytes > 0 && w.Header().Get("Content-Length") == "" {
+		w.Header().Set("Content-Length", strconv.FormatInt(w.bytes, 10))
+	}
+	w.commit()
+}
+
+// Unwrap returns the underlying ResponseWriter, for use by
+// http.ResponseController.
+func (w *headWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// detachableWriter is an http.ResponseWriter that can be detached from
+// the underlying ResponseWriter, for handlers that keep running after
+// ServeHTTP has returned. Once detached, it discards the writes.
+type detachableWriter struct {
+	http.ResponseWriter
+	mu       sync.Mutex
+	detached bool
+	header   http.Header // replaces the underlying header once detached
+}
+
+func (w *detachableWriter) Header() http.Header {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.detached {
+		return w.header
+	}
+	return w.ResponseWriter.Header()
+}
+
+func (w *detachableWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if !w.detached {
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *detachableWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.detached {
+		return 0, context.Canceled
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher if the underlying ResponseWriter does.
+func (w *detachableWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok && !w.detached {
+		f.Flush()
+	}
+}
+
+// detach makes w stop using the underlying ResponseWriter.
+func (w *detachableWriter) detach() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.detached = true
+	w.header = make(http.Header)
+}
+
+// validatorWriter is an http.ResponseWriter that sets the ETag and
+// Last-Modified headers on the 2xx and 304 responses only, so that
+// error responses do not carry the validators of the resource.
+type validatorWriter struct {
+	http.ResponseWriter
+	etag         string
+	lastModified time.Time
+	wroteHeader  bool
+}
+
+func (w *validatorWriter) WriteHeader(code int) {
+	if !w.wroteHeader && code >= 200 {
+		w.wroteHeader = true
+		if code < 300 || code == http.StatusNotModified {
+			if w.etag != "" {
+				w.Header().Set("ETag", w.etag)
+			}
+			if !w.lastModified.IsZero() {
+				w.Header().Set("Last-Modified", w.lastModified.Format(http.TimeFormat))
+			}
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *validatorWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher if the underlying ResponseWriter does.
+func (w *validatorWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying ResponseWriter, for use by
+// http.ResponseController.
+func (w *validatorWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}